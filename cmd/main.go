@@ -2,14 +2,23 @@ package main
 
 import (
 	"demo/go-server/configs"
+	"demo/go-server/internal/applink"
 	"demo/go-server/internal/auth"
+	"demo/go-server/internal/domain"
+	"demo/go-server/internal/folder"
 	"demo/go-server/internal/link"
+	"demo/go-server/internal/policy"
 	"demo/go-server/internal/stat"
+	"demo/go-server/internal/tag"
 	"demo/go-server/internal/user"
+	"demo/go-server/pkg/cache"
 	"demo/go-server/pkg/db"
 	"demo/go-server/pkg/event"
+	"demo/go-server/pkg/geoip"
 	"demo/go-server/pkg/middleware"
+	"demo/go-server/pkg/qr"
 	"fmt"
+	"net"
 	"net/http"
 )
 
@@ -21,17 +30,76 @@ func App() http.Handler {
 
 	// Repositories
 	linkRepo := link.NewLinkRepository(database)
+	if conf.Link.CacheSize > 0 {
+		linkRepo.Cache = link.NewMemoryLinkCache(conf.Link.CacheSize, conf.Link.CacheTTL, conf.Link.CacheNegativeTTL)
+	}
 	userRepo := user.NewUserRepository(database)
 	statRepo := stat.NewStatRepository(database)
+	tagRepo := tag.NewTagRepository(database)
+	folderRepo := folder.NewFolderRepository(database)
+	domainRuleRepo := policy.NewDomainRuleRepository(database)
+	domainRepo := domain.NewDomainRepository(database)
+	if conf.Link.CacheSize > 0 {
+		domainRepo.Hosts = cache.NewLRU[string, uint](1000, nil)
+		domainRepo.HostTTL = conf.Link.CacheTTL
+	}
+
+	geoReader, err := geoip.NewReader(conf.GeoIP.Path)
+	if err != nil {
+		panic(err)
+	}
+	qrLogo, err := qr.LoadLogo(conf.Qr.LogoPath)
+	if err != nil {
+		panic(err)
+	}
 
 	// Services
+	urlPolicy := policy.NewPolicy(conf.Policy, domainRuleRepo)
+	previewFetcher := link.NewPreviewFetcher(conf.Preview.Timeout, conf.Preview.MaxBytes, conf.Preview.MaxRedirects)
+	healthChecker := link.NewHealthChecker(conf.Health.Timeout, conf.Health.MaxRedirects)
+	if conf.Policy.BlockPrivate {
+		transport := &http.Transport{
+			DialContext: (&net.Dialer{Control: policy.DialControl}).DialContext,
+		}
+		previewFetcher.Client.Transport = transport
+		healthChecker.Client.Transport = transport
+	}
 	authService := auth.NewAuthService(userRepo)
 	statService := stat.NewStatService(&stat.StatServiceDeps{
 		StatRepository: statRepo,
 		EventBus:       eventBus,
+		GeoIP:          geoReader,
+		IPMode:         conf.Stat.IPMode,
+		IPSalt:         conf.Stat.IPSalt,
+	})
+
+	previewService := link.NewPreviewService(&link.PreviewServiceDeps{
+		EventBus:       eventBus,
+		LinkRepository: linkRepo,
+		PreviewFetcher: previewFetcher,
+		Timeout:        conf.Preview.Timeout,
+		Workers:        conf.Preview.Workers,
+		QueueSize:      conf.Preview.QueueSize,
+	})
+
+	trashService := link.NewTrashService(&link.TrashServiceDeps{
+		LinkRepository: linkRepo,
+		Retention:      conf.Link.TrashRetention,
+		Interval:       conf.Link.TrashPurgeInterval,
+	})
+
+	healthService := link.NewHealthService(&link.HealthServiceDeps{
+		LinkRepository: linkRepo,
+		HealthChecker:  healthChecker,
+		Interval:       conf.Health.Interval,
+		Concurrency:    conf.Health.Concurrency,
+		HostInterval:   conf.Health.HostInterval,
 	})
 
 	go statService.AddClick()
+	go previewService.FetchPreviews()
+	go trashService.PurgeExpired()
+	go healthService.CheckLinks()
 
 	// Handlers
 	auth.NewAuthHandler(router, auth.AuthHandlerDeps{
@@ -39,12 +107,39 @@ func App() http.Handler {
 		AuthService: authService,
 	})
 	link.NewLinkHandler(router, link.LinkHandlerDeps{
-		LinkRepository: linkRepo,
-		EventBus:       eventBus,
+		LinkRepository:   linkRepo,
+		UserRepository:   userRepo,
+		TagRepository:    tagRepo,
+		FolderRepository: folderRepo,
+		DomainRepository: domainRepo,
+		UrlPolicy:        urlPolicy,
+		GeoIP:            geoReader,
+		EventBus:         eventBus,
+		QrLogo:           qrLogo,
+		Config:           conf,
+	})
+	domain.NewDomainHandler(router, domain.DomainHandlerDeps{
+		DomainRepository: domainRepo,
+		UserRepository:   userRepo,
+		Verifier:         domain.NewVerifier(net.DefaultResolver, conf.Domain.TxtPrefix),
+		Config:           conf,
+	})
+	applink.NewAppLinkHandler(router, applink.AppLinkHandlerDeps{
+		Config: conf,
+	})
+	tag.NewTagHandler(router, tag.TagHandlerDeps{
+		TagRepository:  tagRepo,
+		UserRepository: userRepo,
 		Config:         conf,
 	})
+	folder.NewFolderHandler(router, folder.FolderHandlerDeps{
+		FolderRepository: folderRepo,
+		UserRepository:   userRepo,
+		Config:           conf,
+	})
 	stat.NewStatHandler(router, stat.StatHandlerDeps{
 		StatRepository: statRepo,
+		UserRepository: userRepo,
 		Config:         conf,
 	})
 
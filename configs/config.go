@@ -3,13 +3,27 @@ package configs
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Db   DbConfig
-	Auth AuthConfig
+	// TrustProxy makes client ips come from X-Forwarded-For.
+	TrustProxy bool
+	Db         DbConfig
+	Auth       AuthConfig
+	Link       LinkConfig
+	Preview    PreviewConfig
+	Policy     PolicyConfig
+	GeoIP      GeoIPConfig
+	Qr         QrConfig
+	Health     HealthConfig
+	Domain     DomainConfig
+	AppLink    AppLinkConfig
+	Stat       StatConfig
 }
 
 type DbConfig struct {
@@ -20,17 +34,232 @@ type AuthConfig struct {
 	Secret string
 }
 
+type LinkConfig struct {
+	// BaseUrl prefixes short links, e.g. in QR codes. Empty means the
+	// request host.
+	BaseUrl                 string
+	PasswordMaxAttempts     int
+	PasswordLinkMaxAttempts int
+	PasswordAttemptWindow   time.Duration
+	BulkMaxItems            int
+	BulkMaxBytes            int64
+	HashStrategy            string
+	HashLength              int
+	HashSalt                string
+	InactiveMode            string
+	InactiveUrl             string
+	InactiveMessage         string
+	TrashRetention          time.Duration
+	PageSize                int
+	MaxPageSize             int
+	TrashPurgeInterval      time.Duration
+	ImportMaxRows           int
+	// CacheSize caps the redirect cache in links; 0 turns it off.
+	CacheSize        int
+	CacheTTL         time.Duration
+	CacheNegativeTTL time.Duration
+}
+
+type PreviewConfig struct {
+	Timeout      time.Duration
+	MaxBytes     int64
+	MaxRedirects int
+	Workers      int
+	QueueSize    int
+}
+
+type PolicyConfig struct {
+	AllowedSchemes []string
+	AllowDomains   []string
+	DenyDomains    []string
+	ShortDomains   []string
+	BlockPrivate   bool
+}
+
+type GeoIPConfig struct {
+	// Path of a "network,country" CSV file; empty disables lookups.
+	Path string
+}
+
+type QrConfig struct {
+	// LogoPath is a PNG or JPEG drawn in the centre of codes on request.
+	LogoPath string
+}
+
+type HealthConfig struct {
+	// Interval between checks of all active links; zero disables them.
+	Interval     time.Duration
+	Timeout      time.Duration
+	Concurrency  int
+	HostInterval time.Duration
+	MaxRedirects int
+}
+
+type DomainConfig struct {
+	// Scheme of short urls on custom domains.
+	Scheme string
+	// TxtPrefix is the label under a custom domain that has to carry the
+	// verification TXT record.
+	TxtPrefix string
+}
+
+type StatConfig struct {
+	// IPMode says how click events keep the visitor's IP: "truncate"
+	// (/24 or /48), "hash" (keyed with IPSalt) or "none".
+	IPMode string
+	IPSalt string
+}
+
+type AppLinkConfig struct {
+	// Timeout is how long the deep link page waits for the app before
+	// it falls back.
+	Timeout time.Duration
+	// AppleAppIDs ("TEAMID.bundle.id") and ApplePaths make up the
+	// apple-app-site-association file; no ids means no file.
+	AppleAppIDs []string
+	ApplePaths  []string
+	// AndroidPackage and the SHA-256 fingerprints of its signing
+	// certificates make up assetlinks.json.
+	AndroidPackage      string
+	AndroidFingerprints []string
+}
+
 func LoadConfig() *Config {
 	if err := godotenv.Load(); err != nil {
 		log.Println("Error loading .env file")
 	}
 
 	return &Config{
+		TrustProxy: getEnvBool("TRUST_PROXY", false),
 		Db: DbConfig{
 			Dsn: os.Getenv("DSN"),
 		},
 		Auth: AuthConfig{
 			Secret: os.Getenv("SECRET"),
 		},
+		Link: LinkConfig{
+			BaseUrl:                 os.Getenv("BASE_URL"),
+			PasswordMaxAttempts:     getEnvInt("LINK_PASSWORD_MAX_ATTEMPTS", 5),
+			PasswordLinkMaxAttempts: getEnvInt("LINK_PASSWORD_LINK_MAX_ATTEMPTS", 50),
+			PasswordAttemptWindow:   getEnvDuration("LINK_PASSWORD_ATTEMPT_WINDOW", 15*time.Minute),
+			BulkMaxItems:            getEnvInt("LINK_BULK_MAX_ITEMS", 1000),
+			BulkMaxBytes:            int64(getEnvInt("LINK_BULK_MAX_BYTES", 5<<20)),
+			HashStrategy:            os.Getenv("LINK_HASH_STRATEGY"),
+			HashLength:              getEnvInt("LINK_HASH_LENGTH", 7),
+			HashSalt:                os.Getenv("LINK_HASH_SALT"),
+			InactiveMode:            os.Getenv("LINK_INACTIVE_MODE"),
+			InactiveUrl:             os.Getenv("LINK_INACTIVE_URL"),
+			InactiveMessage:         getEnv("LINK_INACTIVE_MESSAGE", "This link is not active right now."),
+			TrashRetention:          getEnvDuration("LINK_TRASH_RETENTION", 30*24*time.Hour),
+			TrashPurgeInterval:      getEnvDuration("LINK_TRASH_PURGE_INTERVAL", time.Hour),
+			PageSize:                getEnvInt("LINK_PAGE_SIZE", 50),
+			MaxPageSize:             getEnvInt("LINK_MAX_PAGE_SIZE", 500),
+			ImportMaxRows:           getEnvInt("LINK_IMPORT_MAX_ROWS", 50000),
+			CacheSize:               getEnvInt("LINK_CACHE_SIZE", 10000),
+			CacheTTL:                getEnvDuration("LINK_CACHE_TTL", time.Minute),
+			CacheNegativeTTL:        getEnvDuration("LINK_CACHE_NEGATIVE_TTL", 10*time.Second),
+		},
+		Preview: PreviewConfig{
+			Timeout:      getEnvDuration("PREVIEW_TIMEOUT", 5*time.Second),
+			MaxBytes:     int64(getEnvInt("PREVIEW_MAX_BYTES", 512*1024)),
+			MaxRedirects: getEnvInt("PREVIEW_MAX_REDIRECTS", 5),
+			Workers:      getEnvInt("PREVIEW_WORKERS", 4),
+			QueueSize:    getEnvInt("PREVIEW_QUEUE_SIZE", 1000),
+		},
+		Policy: PolicyConfig{
+			AllowedSchemes: getEnvList("POLICY_ALLOWED_SCHEMES", []string{"http", "https"}),
+			AllowDomains:   getEnvList("POLICY_ALLOW_DOMAINS", nil),
+			DenyDomains:    getEnvList("POLICY_DENY_DOMAINS", nil),
+			ShortDomains:   getEnvList("SHORT_DOMAINS", nil),
+			BlockPrivate:   getEnvBool("POLICY_BLOCK_PRIVATE", true),
+		},
+		GeoIP: GeoIPConfig{
+			Path: os.Getenv("GEOIP_DB_PATH"),
+		},
+		Qr: QrConfig{
+			LogoPath: os.Getenv("QR_LOGO_PATH"),
+		},
+		Domain: DomainConfig{
+			Scheme:    getEnv("DOMAIN_SCHEME", "https"),
+			TxtPrefix: getEnv("DOMAIN_TXT_PREFIX", "_shortener"),
+		},
+		Health: HealthConfig{
+			Interval:     getEnvDuration("HEALTH_CHECK_INTERVAL", 6*time.Hour),
+			Timeout:      getEnvDuration("HEALTH_CHECK_TIMEOUT", 10*time.Second),
+			Concurrency:  getEnvInt("HEALTH_CHECK_CONCURRENCY", 8),
+			HostInterval: getEnvDuration("HEALTH_CHECK_HOST_INTERVAL", time.Second),
+			MaxRedirects: getEnvInt("HEALTH_CHECK_MAX_REDIRECTS", 5),
+		},
+		Stat: loadStatConfig(),
+		AppLink: AppLinkConfig{
+			Timeout:             getEnvDuration("APP_LINK_TIMEOUT", 1500*time.Millisecond),
+			AppleAppIDs:         getEnvList("APPLE_APP_IDS", nil),
+			ApplePaths:          getEnvList("APPLE_APP_PATHS", []string{"/*"}),
+			AndroidPackage:      os.Getenv("ANDROID_APP_PACKAGE"),
+			AndroidFingerprints: getEnvList("ANDROID_APP_FINGERPRINTS", nil),
+		},
+	}
+}
+
+// loadStatConfig falls back to truncating IPs when hashing is asked for
+// without a salt, since an unkeyed hash of an IPv4 address is trivially
+// reversed.
+func loadStatConfig() StatConfig {
+	conf := StatConfig{
+		IPMode: getEnv("STAT_IP_MODE", "truncate"),
+		IPSalt: os.Getenv("STAT_IP_SALT"),
+	}
+	if conf.IPMode == "hash" && conf.IPSalt == "" {
+		log.Println("STAT_IP_SALT is empty, storing truncated IPs instead of hashes")
+		conf.IPMode = "truncate"
+	}
+	return conf
+}
+
+func getEnv(key, fallback string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvList reads a comma separated list, dropping empty items.
+func getEnvList(key string, fallback []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
 	}
+	return list
 }
@@ -0,0 +1,76 @@
+package applink
+
+import (
+	"demo/go-server/configs"
+	"demo/go-server/pkg/response"
+	"net/http"
+)
+
+// wellKnownMaxAge is how long clients may cache the association files.
+const wellKnownMaxAge = "public, max-age=3600"
+
+type AppLinkHandlerDeps struct {
+	Config *configs.Config
+}
+
+type AppLinkHandler struct {
+	Config *configs.Config
+}
+
+// NewAppLinkHandler serves the files that make iOS and Android open
+// short links in the configured apps. They are the same on every host.
+func NewAppLinkHandler(router *http.ServeMux, deps AppLinkHandlerDeps) {
+	handler := &AppLinkHandler{
+		Config: deps.Config,
+	}
+	router.HandleFunc("GET /.well-known/apple-app-site-association", handler.AppleAppSiteAssociation())
+	router.HandleFunc("GET /.well-known/assetlinks.json", handler.AssetLinks())
+}
+
+func (handler *AppLinkHandler) AppleAppSiteAssociation() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		conf := handler.Config.AppLink
+		if len(conf.AppleAppIDs) == 0 {
+			http.NotFound(w, req)
+			return
+		}
+
+		details := AppleAppDetails{
+			AppIDs:     conf.AppleAppIDs,
+			Components: make([]AppleComponent, len(conf.ApplePaths)),
+		}
+		for i, path := range conf.ApplePaths {
+			details.Components[i] = AppleComponent{Path: path}
+		}
+
+		w.Header().Set("Cache-Control", wellKnownMaxAge)
+		response.WriteResponse(w, AppleAppSiteAssociation{
+			AppLinks: AppleAppLinks{Details: []AppleAppDetails{details}},
+		}, 200)
+	}
+}
+
+func (handler *AppLinkHandler) AssetLinks() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		conf := handler.Config.AppLink
+		if conf.AndroidPackage == "" {
+			http.NotFound(w, req)
+			return
+		}
+
+		fingerprints := conf.AndroidFingerprints
+		if fingerprints == nil {
+			fingerprints = []string{}
+		}
+
+		w.Header().Set("Cache-Control", wellKnownMaxAge)
+		response.WriteResponse(w, []AssetLink{{
+			Relation: []string{"delegate_permission/common.handle_all_urls"},
+			Target: AssetTarget{
+				Namespace:    "android_app",
+				PackageName:  conf.AndroidPackage,
+				Fingerprints: fingerprints,
+			},
+		}}, 200)
+	}
+}
@@ -0,0 +1,45 @@
+package applink_test
+
+import (
+	"demo/go-server/configs"
+	"demo/go-server/internal/applink"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAppleAppSiteAssociation(t *testing.T) {
+	handler := applink.AppLinkHandler{Config: &configs.Config{AppLink: configs.AppLinkConfig{
+		AppleAppIDs: []string{"ABCDE12345.com.example.app"},
+		ApplePaths:  []string{"/*"},
+	}}}
+
+	wr := httptest.NewRecorder()
+	handler.AppleAppSiteAssociation()(wr, httptest.NewRequest(http.MethodGet, "/.well-known/apple-app-site-association", nil))
+	if wr.Code != http.StatusOK {
+		t.Fatalf("Got %d expected %d", wr.Code, http.StatusOK)
+	}
+
+	var data map[string]any
+	if err := json.Unmarshal(wr.Body.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	}
+	details := data["applinks"].(map[string]any)["details"].([]any)[0].(map[string]any)
+	if details["appIDs"].([]any)[0] != "ABCDE12345.com.example.app" {
+		t.Errorf("Got %v", details)
+	}
+	if details["components"].([]any)[0].(map[string]any)["/"] != "/*" {
+		t.Errorf("Got %v", details)
+	}
+}
+
+func TestAssetLinksNotConfigured(t *testing.T) {
+	handler := applink.AppLinkHandler{Config: &configs.Config{}}
+
+	wr := httptest.NewRecorder()
+	handler.AssetLinks()(wr, httptest.NewRequest(http.MethodGet, "/.well-known/assetlinks.json", nil))
+	if wr.Code != http.StatusNotFound {
+		t.Errorf("Got %d expected %d", wr.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,33 @@
+package applink
+
+// AppleAppSiteAssociation is the apple-app-site-association file that
+// lets iOS open short links in the app.
+type AppleAppSiteAssociation struct {
+	AppLinks AppleAppLinks `json:"applinks"`
+}
+
+type AppleAppLinks struct {
+	Details []AppleAppDetails `json:"details"`
+}
+
+type AppleAppDetails struct {
+	AppIDs     []string         `json:"appIDs"`
+	Components []AppleComponent `json:"components"`
+}
+
+type AppleComponent struct {
+	Path string `json:"/"`
+}
+
+// AssetLink is one statement of assetlinks.json, which lets Android open
+// short links in the app.
+type AssetLink struct {
+	Relation []string    `json:"relation"`
+	Target   AssetTarget `json:"target"`
+}
+
+type AssetTarget struct {
+	Namespace    string   `json:"namespace"`
+	PackageName  string   `json:"package_name"`
+	Fingerprints []string `json:"sha256_cert_fingerprints"`
+}
@@ -0,0 +1,10 @@
+package domain
+
+const (
+	ErrDomainNotFound  = "domain not found"
+	ErrDomainExists    = "domain is already registered"
+	ErrDomainReserved  = "domain is used by the service itself"
+	ErrDomainHasLinks  = "domain still has links"
+	ErrTxtNotFound     = "no TXT record %s with value %s"
+	ErrTxtLookupFailed = "TXT lookup for %s failed: %v"
+)
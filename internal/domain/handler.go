@@ -0,0 +1,208 @@
+package domain
+
+import (
+	"demo/go-server/configs"
+	"demo/go-server/internal/user"
+	"demo/go-server/pkg/di"
+	"demo/go-server/pkg/middleware"
+	"demo/go-server/pkg/request"
+	"demo/go-server/pkg/response"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type DomainHandlerDeps struct {
+	DomainRepository *DomainRepository
+	UserRepository   di.IUserRepository
+	Verifier         *Verifier
+	Config           *configs.Config
+}
+
+type DomainHandler struct {
+	DomainRepository *DomainRepository
+	UserRepository   di.IUserRepository
+	Verifier         *Verifier
+	Config           *configs.Config
+}
+
+func NewDomainHandler(router *http.ServeMux, deps DomainHandlerDeps) {
+	handler := &DomainHandler{
+		DomainRepository: deps.DomainRepository,
+		UserRepository:   deps.UserRepository,
+		Verifier:         deps.Verifier,
+		Config:           deps.Config,
+	}
+	router.Handle("GET /domain", middleware.IsAuthed(handler.GetAll(), deps.Config))
+	router.Handle("POST /domain", middleware.IsAuthed(handler.Create(), deps.Config))
+	router.Handle("POST /domain/{id}/verify", middleware.IsAuthed(handler.Verify(), deps.Config))
+	router.Handle("DELETE /domain/{id}", middleware.IsAuthed(handler.Delete(), deps.Config))
+}
+
+func (handler *DomainHandler) GetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		owner, err := user.FromRequest(req, handler.UserRepository)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		domains := handler.DomainRepository.GetAll(owner.ID)
+		data := make([]DomainResponse, len(domains))
+		for i := range domains {
+			data[i] = handler.describe(&domains[i])
+		}
+
+		response.WriteResponse(w, data, 200)
+	}
+}
+
+// Create registers a host for the caller. It only serves links after
+// Verify has found the TXT record, so a pending claim does not keep the
+// host from other users.
+func (handler *DomainHandler) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		owner, err := user.FromRequest(req, handler.UserRepository)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		body, err := request.HandleBody[DomainRequest](&w, req)
+		if err != nil {
+			return
+		}
+
+		host := NormalizeHost(body.Host)
+		if handler.isServiceHost(req, host) {
+			http.Error(w, ErrDomainReserved, http.StatusUnprocessableEntity)
+			return
+		}
+		if handler.DomainRepository.IsVerifiedHost(host) {
+			http.Error(w, ErrDomainExists, http.StatusConflict)
+			return
+		}
+
+		token, err := newToken()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		domain, err := handler.DomainRepository.Create(&Domain{
+			Host:   host,
+			UserID: owner.ID,
+			Token:  token,
+		})
+		if err != nil {
+			http.Error(w, ErrDomainExists, http.StatusConflict)
+			return
+		}
+
+		response.WriteResponse(w, handler.describe(domain), 201)
+	}
+}
+
+func (handler *DomainHandler) Verify() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		domain, ok := handler.ownedDomain(w, req)
+		if !ok {
+			return
+		}
+
+		if !domain.IsVerified() {
+			if handler.DomainRepository.IsHeldByOther(domain) {
+				http.Error(w, ErrDomainExists, http.StatusConflict)
+				return
+			}
+			if err := handler.Verifier.Verify(req.Context(), domain); err != nil {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			if err := handler.DomainRepository.MarkVerified(domain, time.Now()); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		response.WriteResponse(w, handler.describe(domain), 200)
+	}
+}
+
+// Delete refuses domains that still serve links, in the trash or not,
+// as those would lose their short urls.
+func (handler *DomainHandler) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		domain, ok := handler.ownedDomain(w, req)
+		if !ok {
+			return
+		}
+
+		if handler.DomainRepository.HasLinks(domain.ID) {
+			http.Error(w, ErrDomainHasLinks, http.StatusConflict)
+			return
+		}
+
+		if err := handler.DomainRepository.Delete(domain); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response.WriteResponse(w, nil, 200)
+	}
+}
+
+func (handler *DomainHandler) ownedDomain(w http.ResponseWriter, req *http.Request) (*Domain, bool) {
+	owner, err := user.FromRequest(req, handler.UserRepository)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+
+	id, err := strconv.ParseUint(req.PathValue("id"), 10, 32)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	domain, err := handler.DomainRepository.GetByIdForUser(uint(id), owner.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, ErrDomainNotFound, http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return domain, true
+}
+
+func (handler *DomainHandler) describe(domain *Domain) DomainResponse {
+	return DomainResponse{
+		Domain:   *domain,
+		TxtName:  handler.Verifier.RecordName(domain),
+		TxtValue: handler.Verifier.RecordValue(domain),
+	}
+}
+
+// isServiceHost reports whether host already serves the default short
+// links, which no user may claim.
+func (handler *DomainHandler) isServiceHost(req *http.Request, host string) bool {
+	if host == NormalizeHost(req.Host) {
+		return true
+	}
+	for _, short := range handler.Config.Policy.ShortDomains {
+		if host == NormalizeHost(short) {
+			return true
+		}
+	}
+	if base, err := url.Parse(handler.Config.Link.BaseUrl); err == nil && host == NormalizeHost(base.Host) {
+		return true
+	}
+	return false
+}
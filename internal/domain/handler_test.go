@@ -0,0 +1,201 @@
+package domain_test
+
+import (
+	"context"
+	"demo/go-server/configs"
+	"demo/go-server/internal/domain"
+	"demo/go-server/internal/user"
+	"demo/go-server/pkg/cache"
+	"demo/go-server/pkg/db"
+	"demo/go-server/pkg/middleware"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type MockUserRepository struct {
+}
+
+func (repo *MockUserRepository) Create(u *user.User) (*user.User, error) {
+	return u, nil
+}
+
+func (repo *MockUserRepository) GetByEmail(email string) (*user.User, error) {
+	return &user.User{Model: gorm.Model{ID: 1}, Email: email}, nil
+}
+
+func bootstrap() (*domain.DomainHandler, sqlmock.Sqlmock, error) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		return nil, nil, err
+	}
+	gormDb, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: database,
+	}))
+	if err != nil {
+		return nil, nil, err
+	}
+	handler := domain.DomainHandler{
+		DomainRepository: domain.NewDomainRepository(&db.Db{
+			DB: gormDb,
+		}),
+		UserRepository: &MockUserRepository{},
+		Verifier: domain.NewVerifier(fakeResolver{
+			"_shortener.go.brand.com": {"verify=abc"},
+		}, "_shortener"),
+		Config: &configs.Config{},
+	}
+	return &handler, mock, nil
+}
+
+func authedRequest(method, target string, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	ctx := context.WithValue(req.Context(), middleware.ContextEmailKey, "a@a.com")
+	return req.WithContext(ctx)
+}
+
+func TestCreateAllowsPendingClaims(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// Another user's unverified claim does not show up as verified.
+	mock.ExpectQuery("verified_at is not null").WithArgs("go.brand.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectCommit()
+
+	wr := httptest.NewRecorder()
+	handler.Create()(wr, authedRequest(http.MethodPost, "/domain", `{"host":"go.brand.com"}`))
+
+	if wr.Code != http.StatusCreated {
+		t.Errorf("Got %d expected %d: %s", wr.Code, http.StatusCreated, wr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateVerifiedHost(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	mock.ExpectQuery("verified_at is not null").WithArgs("go.brand.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "host", "user_id"}).AddRow(2, "go.brand.com", 2))
+
+	wr := httptest.NewRecorder()
+	handler.Create()(wr, authedRequest(http.MethodPost, "/domain", `{"host":"go.brand.com"}`))
+
+	if wr.Code != http.StatusConflict {
+		t.Errorf("Got %d expected %d", wr.Code, http.StatusConflict)
+	}
+}
+
+func TestVerifyHostHeldByOther(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	mock.ExpectQuery("SELECT").WithArgs(3, 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "host", "user_id", "token"}).AddRow(3, "go.brand.com", 1, "abc"))
+	mock.ExpectQuery("count").WithArgs("go.brand.com", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	wr := httptest.NewRecorder()
+	req := authedRequest(http.MethodPost, "/domain/3/verify", "")
+	req.SetPathValue("id", "3")
+	handler.Verify()(wr, req)
+
+	if wr.Code != http.StatusConflict {
+		t.Errorf("Got %d expected %d", wr.Code, http.StatusConflict)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVerifyPendingHost(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	mock.ExpectQuery("SELECT").WithArgs(3, 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "host", "user_id", "token"}).AddRow(3, "go.brand.com", 1, "abc"))
+	mock.ExpectQuery("count").WithArgs("go.brand.com", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	wr := httptest.NewRecorder()
+	req := authedRequest(http.MethodPost, "/domain/3/verify", "")
+	req.SetPathValue("id", "3")
+	handler.Verify()(wr, req)
+
+	if wr.Code != http.StatusOK {
+		t.Errorf("Got %d expected %d: %s", wr.Code, http.StatusOK, wr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVerifyForgetsCachedHost(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	handler.DomainRepository.Hosts = cache.NewLRU[string, uint](10, nil)
+	handler.DomainRepository.HostTTL = time.Minute
+
+	// The host is looked up once while pending; the second lookup is cached.
+	mock.ExpectQuery("verified_at is not null").WithArgs("go.brand.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	for range 2 {
+		if id, err := handler.DomainRepository.VerifiedHostID("go.brand.com"); err != nil || id != 0 {
+			t.Fatalf("Got %d, %v expected 0", id, err)
+		}
+	}
+
+	mock.ExpectQuery("SELECT").WithArgs(3, 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "host", "user_id", "token"}).AddRow(3, "go.brand.com", 1, "abc"))
+	mock.ExpectQuery("count").WithArgs("go.brand.com", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	wr := httptest.NewRecorder()
+	req := authedRequest(http.MethodPost, "/domain/3/verify", "")
+	req.SetPathValue("id", "3")
+	handler.Verify()(wr, req)
+	if wr.Code != http.StatusOK {
+		t.Fatalf("Got %d expected %d: %s", wr.Code, http.StatusOK, wr.Body.String())
+	}
+
+	mock.ExpectQuery("verified_at is not null").WithArgs("go.brand.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "host"}).AddRow(3, "go.brand.com"))
+	if id, err := handler.DomainRepository.VerifiedHostID("go.brand.com"); err != nil || id != 3 {
+		t.Errorf("Got %d, %v expected 3", id, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,22 @@
+package domain
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Domain is a host that serves a user's short links once ownership is
+// proven with a DNS TXT record holding Token. Several users may claim the
+// same host, but only one of them can hold it verified.
+type Domain struct {
+	gorm.Model
+	Host       string     `json:"host" gorm:"uniqueIndex:idx_domains_verified_host,where:verified_at IS NOT NULL;uniqueIndex:idx_domains_user_host,priority:2"`
+	UserID     uint       `json:"user_id" gorm:"index;uniqueIndex:idx_domains_user_host,priority:1"`
+	Token      string     `json:"token"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+}
+
+func (domain *Domain) IsVerified() bool {
+	return domain.VerifiedAt != nil
+}
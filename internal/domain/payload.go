@@ -0,0 +1,12 @@
+package domain
+
+type DomainRequest struct {
+	Host string `json:"host" validate:"required,fqdn,max=253"`
+}
+
+// DomainResponse tells the owner which TXT record proves the domain.
+type DomainResponse struct {
+	Domain
+	TxtName  string `json:"txt_name"`
+	TxtValue string `json:"txt_value"`
+}
@@ -0,0 +1,171 @@
+package domain
+
+import (
+	"demo/go-server/pkg/cache"
+	"demo/go-server/pkg/db"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type DomainRepository struct {
+	DataBase *db.Db
+	// Hosts caches VerifiedHostID, 0 standing for hosts that are no
+	// verified domain. Verifying or deleting a domain drops its host. Nil
+	// turns the cache off.
+	Hosts   *cache.LRU[string, uint]
+	HostTTL time.Duration
+}
+
+func NewDomainRepository(database *db.Db) *DomainRepository {
+	return &DomainRepository{
+		DataBase: database,
+	}
+}
+
+func (repo *DomainRepository) Create(domain *Domain) (*Domain, error) {
+	result := repo.DataBase.DB.Create(domain)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return domain, nil
+}
+
+func (repo *DomainRepository) GetById(id uint) (*Domain, error) {
+	var domain Domain
+	result := repo.DataBase.DB.First(&domain, id)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &domain, nil
+}
+
+func (repo *DomainRepository) GetByIdForUser(id, userID uint) (*Domain, error) {
+	var domain Domain
+	result := repo.DataBase.DB.First(&domain, "id = ? and user_id = ?", id, userID)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &domain, nil
+}
+
+// GetVerifiedByHost returns the verified domain serving host.
+func (repo *DomainRepository) GetVerifiedByHost(host string) (*Domain, error) {
+	var domain Domain
+	result := repo.DataBase.DB.
+		Where("host = ? and verified_at is not null", host).
+		First(&domain)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &domain, nil
+}
+
+// VerifiedHostID returns the id of the verified domain serving host, or 0
+// when there is none.
+func (repo *DomainRepository) VerifiedHostID(host string) (uint, error) {
+	if repo.Hosts != nil {
+		if id, ok := repo.Hosts.Get(host); ok {
+			return id, nil
+		}
+	}
+
+	var id uint
+	existed, err := repo.GetVerifiedByHost(host)
+	if err == nil {
+		id = existed.ID
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+
+	if repo.Hosts != nil {
+		repo.Hosts.Set(host, id, repo.HostTTL)
+	}
+	return id, nil
+}
+
+func (repo *DomainRepository) forgetHost(host string) {
+	if repo.Hosts != nil {
+		repo.Hosts.Delete(host)
+	}
+}
+
+// IsVerifiedHost reports whether host is verified by any user.
+func (repo *DomainRepository) IsVerifiedHost(host string) bool {
+	_, err := repo.GetVerifiedByHost(host)
+	return err == nil
+}
+
+// IsHeldByOther reports whether a domain other than domain holds its host
+// verified.
+func (repo *DomainRepository) IsHeldByOther(domain *Domain) bool {
+	var count int64
+	repo.DataBase.DB.
+		Model(&Domain{}).
+		Where("host = ? and id <> ? and verified_at is not null", domain.Host, domain.ID).
+		Count(&count)
+
+	return count > 0
+}
+
+func (repo *DomainRepository) GetAll(userID uint) []Domain {
+	var domains []Domain
+	repo.DataBase.DB.
+		Where("user_id = ?", userID).
+		Order("host asc").
+		Find(&domains)
+
+	return domains
+}
+
+func (repo *DomainRepository) MarkVerified(domain *Domain, at time.Time) error {
+	domain.VerifiedAt = &at
+	err := repo.DataBase.DB.
+		Model(domain).
+		Update("verified_at", at).Error
+	if err == nil {
+		repo.forgetHost(domain.Host)
+	}
+
+	return err
+}
+
+// HasLinks reports whether any link, including those in the trash, is
+// served on the domain.
+func (repo *DomainRepository) HasLinks(id uint) bool {
+	var count int64
+	repo.DataBase.DB.
+		Table("links").
+		Where("domain_id = ?", id).
+		Count(&count)
+
+	return count > 0
+}
+
+// Delete removes the domain for good so the host can be registered
+// again.
+func (repo *DomainRepository) Delete(domain *Domain) error {
+	result := repo.DataBase.DB.
+		Unscoped().
+		Where("user_id = ?", domain.UserID).
+		Delete(&Domain{}, domain.ID)
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	repo.forgetHost(domain.Host)
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+)
+
+// TxtResolver looks up TXT records. *net.Resolver satisfies it; tests
+// and other DNS providers can plug in their own.
+type TxtResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+var _ TxtResolver = (*net.Resolver)(nil)
+
+// Verifier checks that a domain publishes its token as a TXT record on
+// Prefix.<host>.
+type Verifier struct {
+	Resolver TxtResolver
+	Prefix   string
+}
+
+func NewVerifier(resolver TxtResolver, prefix string) *Verifier {
+	return &Verifier{
+		Resolver: resolver,
+		Prefix:   prefix,
+	}
+}
+
+// RecordName is the name the TXT record has to be published under.
+func (v *Verifier) RecordName(domain *Domain) string {
+	return v.Prefix + "." + domain.Host
+}
+
+// RecordValue is the value the TXT record has to carry.
+func (v *Verifier) RecordValue(domain *Domain) string {
+	return "verify=" + domain.Token
+}
+
+func (v *Verifier) Verify(ctx context.Context, domain *Domain) error {
+	name, want := v.RecordName(domain), v.RecordValue(domain)
+
+	records, err := v.Resolver.LookupTXT(ctx, name)
+	if err != nil {
+		return fmt.Errorf(ErrTxtLookupFailed, name, err)
+	}
+	if !slices.ContainsFunc(records, func(record string) bool {
+		return strings.TrimSpace(record) == want
+	}) {
+		return fmt.Errorf(ErrTxtNotFound, name, want)
+	}
+
+	return nil
+}
+
+func newToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// NormalizeHost lowercases host and drops a port and a trailing dot.
+func NormalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
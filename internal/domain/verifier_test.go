@@ -0,0 +1,48 @@
+package domain_test
+
+import (
+	"context"
+	"demo/go-server/internal/domain"
+	"errors"
+	"testing"
+)
+
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	records, ok := r[name]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return records, nil
+}
+
+func TestVerify(t *testing.T) {
+	verifier := domain.NewVerifier(fakeResolver{
+		"_shortener.go.brand.com":  {"v=spf1 -all", " verify=abc "},
+		"_shortener.bad.brand.com": {"verify=other"},
+	}, "_shortener")
+
+	for host, ok := range map[string]bool{
+		"go.brand.com":     true,
+		"bad.brand.com":    false,
+		"absent.brand.com": false,
+	} {
+		err := verifier.Verify(context.Background(), &domain.Domain{Host: host, Token: "abc"})
+		if (err == nil) != ok {
+			t.Errorf("%s: got %v", host, err)
+		}
+	}
+}
+
+func TestNormalizeHost(t *testing.T) {
+	for host, want := range map[string]string{
+		"Go.Brand.com:8080": "go.brand.com",
+		"go.brand.com.":     "go.brand.com",
+		"localhost":         "localhost",
+	} {
+		if got := domain.NormalizeHost(host); got != want {
+			t.Errorf("NormalizeHost(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+package folder
+
+const (
+	ErrFolderNotFound = "folder not found"
+	ErrFolderExists   = "folder already exists"
+)
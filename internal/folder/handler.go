@@ -0,0 +1,39 @@
+package folder
+
+import (
+	"demo/go-server/configs"
+	"demo/go-server/internal/owned"
+	"demo/go-server/pkg/di"
+	"demo/go-server/pkg/middleware"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+type FolderHandlerDeps struct {
+	FolderRepository *FolderRepository
+	UserRepository   di.IUserRepository
+	Config           *configs.Config
+}
+
+type FolderHandler = owned.Handler[Folder]
+
+func NewFolderHandler(router *http.ServeMux, deps FolderHandlerDeps) {
+	handler := NewHandler(deps.FolderRepository, deps.UserRepository)
+	router.Handle("GET /folder", middleware.IsAuthed(handler.GetAll(), deps.Config))
+	router.Handle("POST /folder", middleware.IsAuthed(handler.Create(), deps.Config))
+	router.Handle("PATCH /folder/{id}", middleware.IsAuthed(handler.Update(), deps.Config))
+	router.Handle("DELETE /folder/{id}", middleware.IsAuthed(handler.Delete(), deps.Config))
+}
+
+func NewHandler(repo *FolderRepository, users di.IUserRepository) *FolderHandler {
+	return &FolderHandler{
+		Repository:     repo.Repository,
+		UserRepository: users,
+		New: func(id, userID uint, name string) *Folder {
+			return &Folder{Model: gorm.Model{ID: id}, Name: name, UserID: userID}
+		},
+		ErrNotFound: ErrFolderNotFound,
+		ErrExists:   ErrFolderExists,
+	}
+}
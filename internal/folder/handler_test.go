@@ -0,0 +1,72 @@
+package folder_test
+
+import (
+	"demo/go-server/internal/folder"
+	"demo/go-server/internal/owned/ownedtest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func bootstrap() (*folder.FolderHandler, sqlmock.Sqlmock, error) {
+	database, mock, err := ownedtest.Database()
+	if err != nil {
+		return nil, nil, err
+	}
+	return folder.NewHandler(folder.NewFolderRepository(database), &ownedtest.UserRepository{}), mock, nil
+}
+
+func TestCreateDuplicateFolder(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "folders"`).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "Campaigns", 1).
+		WillReturnError(ownedtest.ErrUniqueViolation)
+	mock.ExpectRollback()
+
+	wr := httptest.NewRecorder()
+	handler.Create()(wr, ownedtest.AuthedRequest(http.MethodPost, "/folder", `{"name":"Campaigns"}`))
+
+	if wr.Code != http.StatusConflict {
+		t.Errorf("Got %d expected %d", wr.Code, http.StatusConflict)
+	}
+	if strings.TrimSpace(wr.Body.String()) != folder.ErrFolderExists {
+		t.Errorf("Got %q expected %q", wr.Body.String(), folder.ErrFolderExists)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeleteFolder(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// Links inside are moved out by the folder_id foreign key.
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "folders" WHERE user_id = \$1 AND "folders"."id" = \$2`).WithArgs(1, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	wr := httptest.NewRecorder()
+	req := ownedtest.AuthedRequest(http.MethodDelete, "/folder/4", "")
+	req.SetPathValue("id", "4")
+	handler.Delete()(wr, req)
+
+	if wr.Code != http.StatusOK {
+		t.Errorf("Got %d expected %d: %s", wr.Code, http.StatusOK, wr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,9 @@
+package folder
+
+import "gorm.io/gorm"
+
+type Folder struct {
+	gorm.Model
+	Name   string `json:"name" gorm:"uniqueIndex:idx_folder_user_name"`
+	UserID uint   `json:"user_id" gorm:"uniqueIndex:idx_folder_user_name"`
+}
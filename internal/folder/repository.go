@@ -0,0 +1,40 @@
+package folder
+
+import (
+	"demo/go-server/internal/owned"
+	"demo/go-server/pkg/db"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FolderRepository stores the caller's folders. Deleting a folder moves
+// the links inside it back to the top level.
+type FolderRepository struct {
+	*owned.Repository[Folder]
+}
+
+func NewFolderRepository(database *db.Db) *FolderRepository {
+	return &FolderRepository{
+		Repository: owned.NewRepository[Folder](database),
+	}
+}
+
+// GetOrCreate returns the user's folder called name, creating it when
+// there is none.
+func (repo *FolderRepository) GetOrCreate(userID uint, name string) (*Folder, error) {
+	folder := Folder{Name: name, UserID: userID}
+	err := repo.DataBase.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&folder).Error
+		if err != nil {
+			return err
+		}
+		return tx.First(&folder, "user_id = ? and name = ?", userID, name).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &folder, nil
+}
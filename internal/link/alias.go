@@ -0,0 +1,44 @@
+package link
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	AliasMinLength = 3
+	AliasMaxLength = 32
+	aliasAlphabet  = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_"
+)
+
+// reservedAliases holds the first path segments already taken by routes,
+// so a short link can never shadow them.
+var reservedAliases = map[string]struct{}{
+	"link":     {},
+	"auth":     {},
+	"stat":     {},
+	"tag":      {},
+	"folder":   {},
+	"domain":   {},
+	"template": {},
+	"api":      {},
+	"admin":    {},
+}
+
+func ValidateAlias(alias string) error {
+	if len(alias) < AliasMinLength || len(alias) > AliasMaxLength {
+		return errors.New(ErrAliasLength)
+	}
+
+	for _, r := range alias {
+		if !strings.ContainsRune(aliasAlphabet, r) {
+			return errors.New(ErrAliasCharset)
+		}
+	}
+
+	if _, ok := reservedAliases[strings.ToLower(alias)]; ok {
+		return errors.New(ErrAliasReserved)
+	}
+
+	return nil
+}
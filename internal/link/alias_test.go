@@ -0,0 +1,28 @@
+package link_test
+
+import (
+	"demo/go-server/internal/link"
+	"testing"
+)
+
+func TestValidateAlias(t *testing.T) {
+	cases := map[string]bool{
+		"spring-sale": true,
+		"Q4_promo":    true,
+		"ab":          false,
+		"link":        false,
+		"Auth":        false,
+		"no spaces":   false,
+		"привет":      false,
+	}
+
+	for alias, valid := range cases {
+		err := link.ValidateAlias(alias)
+		if valid && err != nil {
+			t.Errorf("Alias %q rejected: %s", alias, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Alias %q accepted", alias)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package link
+
+import (
+	"demo/go-server/pkg/useragent"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// blockedDeepLinkSchemes would run code in the page that opens the app.
+var blockedDeepLinkSchemes = map[string]struct{}{
+	"javascript": {},
+	"vbscript":   {},
+	"data":       {},
+	"file":       {},
+}
+
+var appPageTemplate = template.Must(template.New("app").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Opening the app</title>
+</head>
+<body>
+<p><a href="{{.App}}">Open in the app</a></p>
+<p><a href="{{.Web}}" rel="noreferrer">Continue in the browser</a></p>
+<script>
+(function () {
+  var fallback = setTimeout(function () { window.location.replace({{.Fallback}}); }, {{.TimeoutMs}});
+  document.addEventListener("visibilitychange", function () {
+    if (document.hidden) { clearTimeout(fallback); }
+  });
+  window.location.href = {{.AppUri}};
+})();
+</script>
+</body>
+</html>
+`))
+
+type appPage struct {
+	// App is the deep link for the anchor; html/template would otherwise
+	// blank out custom schemes. It is checked by checkAppLinks on save.
+	App       template.URL
+	AppUri    string
+	Fallback  string
+	Web       string
+	TimeoutMs int64
+}
+
+func (body LinkCreateRequest) appLinks() AppLinks {
+	return body.AppLinksRequest.appLinks()
+}
+
+func (body LinkUpdateRequest) appLinks() AppLinks {
+	return body.AppLinksRequest.appLinks()
+}
+
+func (body AppLinksRequest) appLinks() AppLinks {
+	return AppLinks{
+		IosDeepLink:     body.IosDeepLink,
+		AndroidDeepLink: body.AndroidDeepLink,
+		IosStoreUrl:     body.IosStoreUrl,
+		AndroidStoreUrl: body.AndroidStoreUrl,
+	}
+}
+
+// checkAppLinks refuses deep links with script schemes and runs web deep
+// links and store urls through the destination policy.
+func (handler *LinkHandler) checkAppLinks(req *http.Request, body AppLinksRequest) (int, error) {
+	for _, deepLink := range []string{body.IosDeepLink, body.AndroidDeepLink} {
+		if deepLink == "" {
+			continue
+		}
+		parsed, err := url.Parse(deepLink)
+		if err != nil || parsed.Scheme == "" {
+			return http.StatusUnprocessableEntity, errors.New(ErrDeepLinkScheme)
+		}
+		scheme := strings.ToLower(parsed.Scheme)
+		if _, ok := blockedDeepLinkSchemes[scheme]; ok {
+			return http.StatusUnprocessableEntity, errors.New(ErrDeepLinkScheme)
+		}
+		if scheme == "http" || scheme == "https" {
+			if status, err := handler.checkUrl(req, deepLink); err != nil {
+				return status, err
+			}
+		}
+	}
+
+	for _, storeUrl := range []string{body.IosStoreUrl, body.AndroidStoreUrl} {
+		if storeUrl == "" {
+			continue
+		}
+		if status, err := handler.checkUrl(req, storeUrl); err != nil {
+			return status, err
+		}
+	}
+
+	return 0, nil
+}
+
+// appTarget returns the deep link for the platform of the visitor and
+// where to go when the app does not open: the store page if there is
+// one, destination otherwise.
+func (link *Link) appTarget(platform, destination string) (app, fallback string, ok bool) {
+	var store string
+	switch platform {
+	case useragent.PlatformIOS:
+		app, store = link.IosDeepLink, link.IosStoreUrl
+	case useragent.PlatformAndroid:
+		app, store = link.AndroidDeepLink, link.AndroidStoreUrl
+	}
+	if app == "" {
+		return "", "", false
+	}
+
+	if store == "" {
+		store = destination
+	}
+	return app, store, true
+}
+
+// openApp serves the page that tries the deep link of the link and falls
+// back after the configured timeout. It reports false when the visitor's
+// platform has no deep link, so the caller redirects as usual.
+func (handler *LinkHandler) openApp(w http.ResponseWriter, req *http.Request, link *Link, destination string) bool {
+	app, fallback, ok := link.appTarget(useragent.Platform(req.UserAgent()), destination)
+	if !ok {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_ = appPageTemplate.Execute(w, appPage{
+		App:       template.URL(app),
+		AppUri:    app,
+		Fallback:  fallback,
+		Web:       destination,
+		TimeoutMs: handler.Config.AppLink.Timeout.Milliseconds(),
+	})
+	return true
+}
@@ -0,0 +1,278 @@
+package link
+
+import (
+	"demo/go-server/pkg/event"
+	"demo/go-server/pkg/request"
+	"demo/go-server/pkg/response"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type bulkItem struct {
+	Request LinkCreateRequest
+	Err     error
+}
+
+// BulkCreate accepts a JSON array of link create requests or a CSV file
+// (as the raw body or a multipart "file" field).
+func (handler *LinkHandler) BulkCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, ok := handler.currentUserID(w, req)
+		if !ok {
+			return
+		}
+
+		maxBytes := handler.Config.Link.BulkMaxBytes
+		req.Body = http.MaxBytesReader(w, req.Body, maxBytes)
+		items, err := decodeBulkItems(req, handler.Config.Link.BulkMaxItems+1)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf(ErrBulkBodyTooLarge, maxBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		handler.createBatch(w, req, userID, items)
+	}
+}
+
+// createBatch validates and builds every item and stores the links, in
+// one transaction, only when all of them are valid. Otherwise it answers
+// 422 with the problem of each item.
+func (handler *LinkHandler) createBatch(w http.ResponseWriter, req *http.Request, userID uint, items []bulkItem) {
+	if len(items) == 0 {
+		http.Error(w, ErrBulkEmpty, http.StatusBadRequest)
+		return
+	}
+	if len(items) > handler.Config.Link.BulkMaxItems {
+		http.Error(w, ErrBulkTooLarge, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	req = req.WithContext(handler.UrlPolicy.WithRules(req.Context()))
+
+	data := LinkBulkCreateResponse{
+		Results: make([]LinkBulkResult, len(items)),
+	}
+	links := make([]*Link, 0, len(items))
+	taken := make(map[string]struct{}, len(items))
+
+	for i, item := range items {
+		result := &data.Results[i]
+		result.Index = i
+
+		if item.Err == nil {
+			item.Err = request.IsValid(item.Request)
+		}
+		if item.Err != nil {
+			result.Status = http.StatusUnprocessableEntity
+			result.Error = item.Err.Error()
+			data.Failed++
+			continue
+		}
+
+		link, status, err := handler.buildLink(req, &item.Request, userID, taken)
+		if err != nil {
+			result.Status = status
+			result.Error = err.Error()
+			data.Failed++
+			continue
+		}
+
+		taken[hashKey(link.DomainID, link.Hash)] = struct{}{}
+		links = append(links, link)
+		result.Link = link
+	}
+
+	if data.Failed > 0 {
+		for i := range data.Results {
+			data.Results[i].Link = nil
+		}
+		response.WriteResponse(w, data, http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err := handler.LinkRepository.CreateMany(links); err != nil {
+		writeCreateError(w, err)
+		return
+	}
+
+	for i := range data.Results {
+		data.Results[i].Status = http.StatusCreated
+	}
+	go func() {
+		for _, link := range links {
+			handler.EventBus.Publish(event.Event{
+				Type: event.LinkCreated,
+				Data: link.ID,
+			})
+		}
+	}()
+	handler.present(req, links...)
+	data.Created = len(links)
+	response.WriteResponse(w, data, 201)
+}
+
+func (handler *LinkHandler) BulkDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, ok := handler.currentUserID(w, req)
+		if !ok {
+			return
+		}
+
+		body, err := request.HandleBody[LinkBulkDeleteRequest](&w, req)
+		if err != nil {
+			return
+		}
+		if len(body.Ids) > handler.Config.Link.BulkMaxItems {
+			http.Error(w, ErrBulkTooLarge, http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		deleted, err := handler.LinkRepository.DeleteMany(body.Ids, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		deletedSet := make(map[uint]struct{}, len(deleted))
+		for _, id := range deleted {
+			deletedSet[id] = struct{}{}
+		}
+		notFound := []uint{}
+		for _, id := range body.Ids {
+			if _, ok := deletedSet[id]; !ok {
+				notFound = append(notFound, id)
+			}
+		}
+
+		response.WriteResponse(w, LinkBulkDeleteResponse{
+			Deleted:  deleted,
+			NotFound: notFound,
+		}, 200)
+	}
+}
+
+// decodeBulkItems reads at most limit items, so an oversized batch is
+// refused without decoding all of it.
+func decodeBulkItems(req *http.Request, limit int) ([]bulkItem, error) {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case "text/csv":
+		return parseBulkCsv(req.Body, limit)
+	case "multipart/form-data":
+		file, _, err := req.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return parseBulkCsv(file, limit)
+	default:
+		return parseBulkJson(req.Body, limit)
+	}
+}
+
+// parseBulkJson reads a JSON array of link create requests.
+func parseBulkJson(r io.Reader, limit int) ([]bulkItem, error) {
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); err != nil {
+		return nil, err
+	} else if token != json.Delim('[') {
+		return nil, errors.New(ErrBulkJson)
+	}
+
+	var items []bulkItem
+	for decoder.More() && len(items) < limit {
+		var item bulkItem
+		if err := decoder.Decode(&item.Request); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// parseBulkCsv reads rows with a header naming the columns; url is
+// required, alias, expires_at (RFC 3339), max_clicks, password and the
+// utm_* fields are optional. Row level problems are kept on the item instead of failing
+// the whole file.
+func parseBulkCsv(r io.Reader, limit int) ([]bulkItem, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New(ErrBulkCsvHeader)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["url"]; !ok {
+		return nil, errors.New(ErrBulkCsvHeader)
+	}
+
+	var items []bulkItem
+	for len(items) < limit {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		item := bulkItem{
+			Request: LinkCreateRequest{
+				Url:         field("url"),
+				Alias:       field("alias"),
+				Password:    field("password"),
+				UtmSource:   field("utm_source"),
+				UtmMedium:   field("utm_medium"),
+				UtmCampaign: field("utm_campaign"),
+				UtmTerm:     field("utm_term"),
+				UtmContent:  field("utm_content"),
+			},
+		}
+		if value := field("expires_at"); value != "" {
+			expiresAt, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				item.Err = err
+			}
+			item.Request.ExpiresAt = &expiresAt
+		}
+		if value := field("max_clicks"); value != "" {
+			maxClicks, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				item.Err = err
+			}
+			clicks := uint(maxClicks)
+			item.Request.MaxClicks = &clicks
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
@@ -0,0 +1,196 @@
+package link
+
+import (
+	"demo/go-server/pkg/cache"
+	"demo/go-server/pkg/response"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LinkCache sits in front of GetByHash, keyed by hashKey. A nil link is
+// a negative entry: the hash is known not to exist.
+//
+// Generation changes with every invalidation. Set is handed the
+// generation seen before the link was read and stores nothing when it has
+// changed since, as the read may have raced with a write.
+type LinkCache interface {
+	Get(key string) (link *Link, found bool)
+	Generation() uint64
+	Set(key string, link *Link, generation uint64)
+	Invalidate(key string)
+	InvalidateLink(id uint)
+	Stats() LinkCacheStats
+}
+
+type LinkCacheStats struct {
+	cache.Stats
+	NegativeHits uint64 `json:"negative_hits"`
+}
+
+// MemoryLinkCache is an in-process LinkCache: an LRU whose entries live
+// for TTL, or NegativeTTL for unknown hashes.
+type MemoryLinkCache struct {
+	lru          *cache.LRU[string, *Link]
+	ttl          time.Duration
+	negativeTTL  time.Duration
+	negativeHits atomic.Uint64
+
+	mu   sync.Mutex
+	keys map[uint]string
+
+	// genMu makes the generation check of Set and the write that follows
+	// one step with respect to invalidations.
+	genMu      sync.Mutex
+	generation uint64
+}
+
+func NewMemoryLinkCache(size int, ttl, negativeTTL time.Duration) *MemoryLinkCache {
+	c := &MemoryLinkCache{
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		keys:        make(map[uint]string),
+	}
+	c.lru = cache.NewLRU(size, c.evicted)
+	return c
+}
+
+func (c *MemoryLinkCache) Get(key string) (*Link, bool) {
+	link, ok := c.lru.Get(key)
+	if ok && link == nil {
+		c.negativeHits.Add(1)
+	}
+	return link, ok
+}
+
+func (c *MemoryLinkCache) Generation() uint64 {
+	c.genMu.Lock()
+	defer c.genMu.Unlock()
+	return c.generation
+}
+
+func (c *MemoryLinkCache) Set(key string, link *Link, generation uint64) {
+	c.genMu.Lock()
+	defer c.genMu.Unlock()
+	if c.generation != generation {
+		return
+	}
+
+	if link == nil {
+		if c.negativeTTL > 0 {
+			c.lru.Set(key, nil, c.negativeTTL)
+		}
+		return
+	}
+
+	c.lru.Set(key, link, c.ttl)
+	c.mu.Lock()
+	c.keys[link.ID] = key
+	c.mu.Unlock()
+}
+
+func (c *MemoryLinkCache) Invalidate(key string) {
+	c.bump()
+	c.lru.Delete(key)
+}
+
+// InvalidateLink drops the entry of a link by id, for changes made
+// without knowing its hash.
+func (c *MemoryLinkCache) InvalidateLink(id uint) {
+	c.bump()
+	c.mu.Lock()
+	key, ok := c.keys[id]
+	c.mu.Unlock()
+
+	if ok {
+		c.lru.Delete(key)
+	}
+}
+
+// bump starts a new generation. It runs before the entry is dropped, so
+// a Set racing with the invalidation either sees the new generation or is
+// undone by the drop.
+func (c *MemoryLinkCache) bump() {
+	c.genMu.Lock()
+	c.generation++
+	c.genMu.Unlock()
+}
+
+func (c *MemoryLinkCache) Stats() LinkCacheStats {
+	return LinkCacheStats{
+		Stats:        c.lru.Stats(),
+		NegativeHits: c.negativeHits.Load(),
+	}
+}
+
+func (c *MemoryLinkCache) evicted(key string, link *Link) {
+	if link == nil {
+		return
+	}
+	c.mu.Lock()
+	if c.keys[link.ID] == key {
+		delete(c.keys, link.ID)
+	}
+	c.mu.Unlock()
+}
+
+// deferredCache holds back invalidations made inside a transaction
+// until it commits, so a concurrent read can't cache the old row again
+// in between. Reads inside the transaction are not cached: they may see
+// rows that are rolled back.
+type deferredCache struct {
+	LinkCache
+	keys []string
+	ids  []uint
+}
+
+func (c *deferredCache) Set(key string, link *Link, generation uint64) {}
+
+func (c *deferredCache) Invalidate(key string) {
+	c.keys = append(c.keys, key)
+}
+
+func (c *deferredCache) InvalidateLink(id uint) {
+	c.ids = append(c.ids, id)
+}
+
+func (c *deferredCache) flush() {
+	for _, key := range c.keys {
+		c.LinkCache.Invalidate(key)
+	}
+	for _, id := range c.ids {
+		c.LinkCache.InvalidateLink(id)
+	}
+}
+
+// forget drops cached entries of links changed by id.
+func (repo *LinkRepository) forget(ids ...uint) {
+	if repo.Cache == nil {
+		return
+	}
+	for _, id := range ids {
+		repo.Cache.InvalidateLink(id)
+	}
+}
+
+// forgetHash drops the entry, negative or not, of a hash on a domain.
+func (repo *LinkRepository) forgetHash(domainID *uint, hash string) {
+	if repo.Cache != nil {
+		repo.Cache.Invalidate(hashKey(domainID, hash))
+	}
+}
+
+func (handler *LinkHandler) CacheStats() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if _, ok := handler.currentUserID(w, req); !ok {
+			return
+		}
+
+		var stats LinkCacheStats
+		if handler.LinkRepository.Cache != nil {
+			stats = handler.LinkRepository.Cache.Stats()
+		}
+		response.WriteResponse(w, stats, 200)
+	}
+}
@@ -0,0 +1,33 @@
+package link_test
+
+import (
+	"demo/go-server/internal/link"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestCacheSkipsStaleSet(t *testing.T) {
+	cache := link.NewMemoryLinkCache(10, time.Minute, time.Minute)
+
+	// A read that started before the link was invalidated must not cache
+	// what it read.
+	generation := cache.Generation()
+	cache.InvalidateLink(5)
+	cache.Set("qwerty", &link.Link{Model: gorm.Model{ID: 5}, Url: "https://old.test"}, generation)
+	if _, ok := cache.Get("qwerty"); ok {
+		t.Error("Stale link was cached")
+	}
+
+	generation = cache.Generation()
+	cache.Set("qwerty", &link.Link{Model: gorm.Model{ID: 5}, Url: "https://new.test"}, generation)
+	if cached, ok := cache.Get("qwerty"); !ok || cached.Url != "https://new.test" {
+		t.Errorf("Got %v, %v expected the new link", cached, ok)
+	}
+
+	cache.InvalidateLink(5)
+	if _, ok := cache.Get("qwerty"); ok {
+		t.Error("Invalidated link is still cached")
+	}
+}
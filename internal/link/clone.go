@@ -0,0 +1,133 @@
+package link
+
+import (
+	"demo/go-server/pkg/event"
+	"demo/go-server/pkg/request"
+	"demo/go-server/pkg/response"
+	"net/http"
+)
+
+// Clone copies a link with its metadata, routing, variants, tags and
+// expiry settings under a new hash, or under the alias in the body.
+// Clicks and history start from zero.
+func (handler *LinkHandler) Clone() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, ok := handler.currentUserID(w, req)
+		if !ok {
+			return
+		}
+
+		alias := ""
+		if req.ContentLength > 0 {
+			body, err := request.HandleBody[LinkCloneRequest](&w, req)
+			if err != nil {
+				return
+			}
+			alias = body.Alias
+		}
+
+		source, ok := handler.ownedLinkFrom(w, req, userID, handler.LinkRepository.GetWithRelations)
+		if !ok {
+			return
+		}
+
+		req = req.WithContext(handler.UrlPolicy.WithRules(req.Context()))
+		if status, err := handler.checkDestinations(req, source); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		link := source.clone()
+		if status, err := handler.assignHash(link, alias, nil); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		createdLink, err := handler.LinkRepository.Create(link)
+		if err != nil {
+			writeCreateError(w, err)
+			return
+		}
+
+		go handler.EventBus.Publish(event.Event{
+			Type: event.LinkCreated,
+			Data: createdLink.ID,
+		})
+
+		handler.present(req, createdLink)
+		response.WriteResponse(w, createdLink, 201)
+	}
+}
+
+// clone returns an unsaved copy of the link without hash and clicks.
+func (link *Link) clone() *Link {
+	copied := NewLink(link.Url, link.UserID)
+	copied.Title = link.Title
+	copied.Description = link.Description
+	copied.Image = link.Image
+	copied.Favicon = link.Favicon
+	copied.FolderID = link.FolderID
+	copied.DomainID = link.DomainID
+	copied.Tags = link.Tags
+	copied.Active = link.Active
+	copied.StartsAt = link.StartsAt
+	copied.RedirectCode = link.RedirectCode
+	copied.RedirectMode = link.RedirectMode
+	copied.ForwardQuery = link.ForwardQuery
+	copied.UtmSource = link.UtmSource
+	copied.UtmMedium = link.UtmMedium
+	copied.UtmCampaign = link.UtmCampaign
+	copied.UtmTerm = link.UtmTerm
+	copied.UtmContent = link.UtmContent
+	copied.AppLinks = link.AppLinks
+	copied.ExpiresAt = link.ExpiresAt
+	copied.MaxClicks = link.MaxClicks
+	copied.Password = link.Password
+
+	for _, rule := range link.Rules {
+		copied.Rules = append(copied.Rules, RoutingRule{
+			Position: rule.Position,
+			Platform: rule.Platform,
+			Language: rule.Language,
+			Country:  rule.Country,
+			FromHour: rule.FromHour,
+			ToHour:   rule.ToHour,
+			Timezone: rule.Timezone,
+			Url:      rule.Url,
+		})
+	}
+	for _, variant := range link.Variants {
+		copied.Variants = append(copied.Variants, Variant{
+			Name:   variant.Name,
+			Url:    variant.Url,
+			Weight: variant.Weight,
+		})
+	}
+
+	return copied
+}
+
+// checkDestinations runs every url the link can redirect to through the
+// destination policy: the policy may have changed since it was saved.
+func (handler *LinkHandler) checkDestinations(req *http.Request, link *Link) (int, error) {
+	if status, err := handler.checkUrl(req, link.Url); err != nil {
+		return status, err
+	}
+	for _, rule := range link.Rules {
+		if status, err := handler.checkUrl(req, rule.Url); err != nil {
+			return status, err
+		}
+	}
+	for _, variant := range link.Variants {
+		if status, err := handler.checkUrl(req, variant.Url); err != nil {
+			return status, err
+		}
+	}
+
+	return handler.checkAppLinks(req, AppLinksRequest{
+		IosDeepLink:     link.AppLinks.IosDeepLink,
+		AndroidDeepLink: link.AppLinks.AndroidDeepLink,
+		IosStoreUrl:     link.AppLinks.IosStoreUrl,
+		AndroidStoreUrl: link.AppLinks.AndroidStoreUrl,
+	})
+}
@@ -0,0 +1,99 @@
+package link
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	SortCreatedAt = "created_at"
+	SortUpdatedAt = "updated_at"
+	SortUrl       = "url"
+	SortClicks    = "clicks"
+)
+
+// linkSortColumns maps the sort names accepted by GET /link to columns.
+var linkSortColumns = map[string]string{
+	SortCreatedAt: "links.created_at",
+	SortUpdatedAt: "links.updated_at",
+	SortUrl:       "links.url",
+	SortClicks:    clickTotalSql,
+}
+
+// clickTotalSql sums the daily click counters of a link. links.clicks is
+// no total: it only counts visits of links with a click limit.
+const clickTotalSql = "(select coalesce(sum(stats.clicks), 0) from stats where stats.link_id = links.id and stats.deleted_at is null)"
+
+// LinkCursor marks the last link of a page: its sort value and id, which
+// breaks ties. The sort it was made for travels along so a cursor can't
+// be replayed against another order.
+type LinkCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+func newLinkCursor(link *Link, sort string, desc bool) *LinkCursor {
+	cursor := &LinkCursor{Sort: sort, Desc: desc, ID: link.ID}
+	switch sort {
+	case SortCreatedAt:
+		cursor.Value = link.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case SortUpdatedAt:
+		cursor.Value = link.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	case SortUrl:
+		cursor.Value = link.Url
+	case SortClicks:
+		var total int64
+		if link.TotalClicks != nil {
+			total = *link.TotalClicks
+		}
+		cursor.Value = strconv.FormatInt(total, 10)
+	}
+	return cursor
+}
+
+// Encode returns the opaque form handed out as "next".
+func (cursor *LinkCursor) Encode() string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// errCursorValue reports a cursor whose value does not fit its sort.
+var errCursorValue = errors.New(ErrInvalidCursor)
+
+func decodeLinkCursor(value, sort string, desc bool) (*LinkCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New(ErrInvalidCursor)
+	}
+
+	var cursor LinkCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, errors.New(ErrInvalidCursor)
+	}
+	if cursor.Sort != sort || cursor.Desc != desc {
+		return nil, errors.New(ErrCursorSort)
+	}
+	if _, err := cursor.value(); err != nil {
+		return nil, errCursorValue
+	}
+
+	return &cursor, nil
+}
+
+// value returns the sort value typed like its column.
+func (cursor *LinkCursor) value() (any, error) {
+	switch cursor.Sort {
+	case SortCreatedAt, SortUpdatedAt:
+		return time.Parse(time.RFC3339Nano, cursor.Value)
+	case SortClicks:
+		return strconv.ParseInt(cursor.Value, 10, 64)
+	case SortUrl:
+		return cursor.Value, nil
+	}
+	return nil, errors.New(ErrInvalidSort)
+}
@@ -0,0 +1,90 @@
+package link
+
+import (
+	"demo/go-server/internal/domain"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// requestDomainID returns the verified custom domain the request came in
+// on, or nil for the default hosts.
+func (handler *LinkHandler) requestDomainID(req *http.Request) *uint {
+	if handler.DomainRepository == nil {
+		return nil
+	}
+
+	id, err := handler.DomainRepository.VerifiedHostID(domain.NormalizeHost(req.Host))
+	if err != nil || id == 0 {
+		return nil
+	}
+	return &id
+}
+
+// checkDomain makes sure the domain belongs to userID and is verified.
+func (handler *LinkHandler) checkDomain(domainID, userID uint) (int, error) {
+	existed, err := handler.DomainRepository.GetByIdForUser(domainID, userID)
+	if err != nil || !existed.IsVerified() {
+		return http.StatusUnprocessableEntity, errors.New(ErrUnknownDomain)
+	}
+
+	return 0, nil
+}
+
+// isCustomHost reports whether the host of target is a verified custom
+// domain, where a destination would loop back to us.
+func (handler *LinkHandler) isCustomHost(target string) (string, bool) {
+	parsed, err := url.Parse(target)
+	if err != nil || handler.DomainRepository == nil {
+		return "", false
+	}
+
+	host := domain.NormalizeHost(parsed.Host)
+	return host, host != "" && handler.DomainRepository.IsVerifiedHost(host)
+}
+
+// hashKey identifies a hash on its domain inside a batch.
+func hashKey(domainID *uint, hash string) string {
+	if domainID == nil {
+		return hash
+	}
+	return fmt.Sprintf("%d/%s", *domainID, hash)
+}
+
+// shortUrl builds the public short url of link: on its custom domain,
+// else on the configured base url, else on the host of the request.
+// The domain has to be loaded already, see present.
+func (handler *LinkHandler) shortUrl(req *http.Request, link *Link) string {
+	if link.DomainID != nil && link.Domain != nil {
+		return handler.Config.Domain.Scheme + "://" + link.Domain.Host + "/" + link.Hash
+	}
+
+	if base := handler.Config.Link.BaseUrl; base != "" {
+		return strings.TrimSuffix(base, "/") + "/" + link.Hash
+	}
+
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + req.Host + "/" + link.Hash
+}
+
+// present fills in the short url of links about to be sent out. Domains
+// are looked up once per call.
+func (handler *LinkHandler) present(req *http.Request, links ...*Link) {
+	domains := map[uint]*domain.Domain{}
+	for _, link := range links {
+		if link.DomainID != nil && link.Domain == nil {
+			if existed, ok := domains[*link.DomainID]; ok {
+				link.Domain = existed
+			} else if handler.DomainRepository != nil {
+				link.Domain, _ = handler.DomainRepository.GetById(*link.DomainID)
+				domains[*link.DomainID] = link.Domain
+			}
+		}
+		link.ShortUrl = handler.shortUrl(req, link)
+	}
+}
@@ -0,0 +1,52 @@
+package link
+
+const (
+	ErrLinkNotFound     = "link not found"
+	ErrForbidden        = "link belongs to another user"
+	ErrAliasTaken       = "alias is already taken"
+	ErrAliasLength      = "alias must be between 3 and 32 characters"
+	ErrAliasCharset     = "alias may only contain letters, digits, '-' and '_'"
+	ErrAliasReserved    = "alias is reserved"
+	ErrLinkExpired      = "link has expired"
+	ErrLinkInactive     = "link is not active"
+	ErrWrongPassword    = "wrong password"
+	ErrTooManyTries     = "too many attempts, try again later"
+	ErrBulkEmpty        = "no links provided"
+	ErrBulkTooLarge     = "too many links in one request"
+	ErrBulkCsvHeader    = "csv must have a header row with a url column"
+	ErrBulkJson         = "body must be a json array of links"
+	ErrBulkBodyTooLarge = "request body must be at most %d bytes"
+	ErrInvalidHash      = "hash contains characters outside the alphabet"
+	ErrUnknownFolder    = "folder not found"
+	ErrUnknownDomain    = "domain not found or not verified"
+	ErrTooManyRedirects = "too many redirects"
+	ErrNotHtml          = "target is not an html page"
+	ErrQrFormat         = "format must be png or svg"
+	ErrQrSize           = "size must be between %d and %d"
+	ErrQrLevel          = "level must be one of L, M, Q, H"
+	ErrQrLogo           = "logo must be true or false"
+	ErrRevisionNotFound = "revision not found"
+	ErrHealthUnchecked  = "link has not been checked yet"
+	ErrInvalidHealth    = "health must be broken, healthy or unchecked"
+	ErrInvalidCursor    = "invalid cursor"
+	ErrTimezone         = "unknown timezone %s"
+	ErrOffsetRemoved    = "offset is no longer supported, page with the cursor from next"
+	ErrCursorSort       = "cursor was made for another sort order"
+	ErrInvalidSort      = "sort must be one of created_at, updated_at, url, clicks"
+	ErrInvalidOrder     = "order must be asc or desc"
+	ErrInvalidLimit     = "limit must be between 1 and %d"
+	ErrInvalidDate      = "%s must be a date (2006-01-02) or an RFC 3339 time"
+	ErrTemplateNotFound = "template not found"
+	ErrTemplatePassword = "templates cannot carry a password"
+	ErrTemplateCsv      = "csv must have a header row naming the template variables"
+	ErrMissingVariable  = "missing value for {{%s}}"
+	ErrQrNoLogo         = "no qr logo is configured"
+	ErrExportFormat     = "format must be csv, json or ndjson"
+	ErrImportFormat     = "format must be csv, json, ndjson or bitly"
+	ErrImportJson       = "json import must be an array of links"
+	ErrImportTooLarge   = "too many rows, at most %d per import"
+	ErrImportProtected  = "link is password protected but the row has no password or password_hash"
+	ErrImportHash       = "password_hash is not a bcrypt hash"
+	ErrOnConflict       = "on_conflict must be skip, rename or overwrite"
+	ErrDeepLinkScheme   = "deep link must be an absolute uri with an app or web scheme"
+)
@@ -0,0 +1,359 @@
+package link
+
+import (
+	"demo/go-server/internal/tag"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCsv    = "csv"
+	FormatJson   = "json"
+	FormatNdjson = "ndjson"
+	FormatBitly  = "bitly"
+)
+
+// exportBatchSize is how many links Export loads from the database at a
+// time.
+const exportBatchSize = 500
+
+// exportColumns are the CSV columns of an export, in order. Import reads
+// the same names.
+var exportColumns = []string{
+	"hash", "short_url", "url", "domain", "title", "description", "tags", "folder",
+	"active", "redirect_code", "redirect_mode", "forward_query",
+	"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content",
+	"ios_deep_link", "android_deep_link", "ios_store_url", "android_store_url",
+	"starts_at", "expires_at", "max_clicks", "protected", "password_hash", "clicks", "created_at",
+}
+
+var exportContentTypes = map[string]string{
+	FormatCsv:    "text/csv; charset=utf-8",
+	FormatJson:   "application/json",
+	FormatNdjson: "application/x-ndjson",
+}
+
+// Export streams every live link of the caller in the format asked for,
+// loading them batch by batch.
+func (handler *LinkHandler) Export() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, ok := handler.currentUserID(w, req)
+		if !ok {
+			return
+		}
+
+		format := req.URL.Query().Get("format")
+		if format == "" {
+			format = FormatJson
+		}
+		contentType, ok := exportContentTypes[format]
+		if !ok {
+			http.Error(w, ErrExportFormat, http.StatusBadRequest)
+			return
+		}
+
+		filename := "links-" + time.Now().Format(time.DateOnly) + "." + format
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		writer := newExportWriter(format, w)
+		flusher := http.NewResponseController(w)
+
+		err := handler.LinkRepository.ExportBatches(userID, exportBatchSize, func(links []Link) error {
+			ids := make([]uint, len(links))
+			page := make([]*Link, len(links))
+			for i := range links {
+				ids[i] = links[i].ID
+				page[i] = &links[i]
+			}
+			handler.present(req, page...)
+			clicks := handler.LinkRepository.ClickTotals(ids)
+
+			for _, link := range page {
+				if err := writer.Write(exportOf(link, clicks[link.ID])); err != nil {
+					return err
+				}
+			}
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			if err := flusher.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+			return nil
+		})
+		if err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			log.Println("Export for user", userID, "failed:", err)
+		}
+	}
+}
+
+func exportOf(link *Link, clicks int64) LinkExport {
+	export := LinkExport{
+		Hash:         link.Hash,
+		ShortUrl:     link.ShortUrl,
+		Url:          link.Url,
+		Title:        link.Title,
+		Description:  link.Description,
+		Active:       &link.Active,
+		RedirectCode: link.RedirectCode,
+		RedirectMode: link.RedirectMode,
+		ForwardQuery: link.ForwardQuery,
+		UtmSource:    link.UtmSource,
+		UtmMedium:    link.UtmMedium,
+		UtmCampaign:  link.UtmCampaign,
+		UtmTerm:      link.UtmTerm,
+		UtmContent:   link.UtmContent,
+		AppLinksRequest: AppLinksRequest{
+			IosDeepLink:     link.IosDeepLink,
+			AndroidDeepLink: link.AndroidDeepLink,
+			IosStoreUrl:     link.IosStoreUrl,
+			AndroidStoreUrl: link.AndroidStoreUrl,
+		},
+		StartsAt:     link.StartsAt,
+		ExpiresAt:    link.ExpiresAt,
+		MaxClicks:    link.MaxClicks,
+		Protected:    link.IsProtected(),
+		PasswordHash: link.Password,
+		Clicks:       clicks,
+		CreatedAt:    &link.CreatedAt,
+	}
+	if link.Domain != nil {
+		export.Domain = link.Domain.Host
+	}
+	if link.Folder != nil {
+		export.Folder = link.Folder.Name
+	}
+	export.Tags = tagNames(link.Tags)
+	export.Rules = ruleRequests(link.Rules)
+	export.Variants = variantRequests(link.Variants)
+
+	return export
+}
+
+func tagNames(tags []tag.Tag) []string {
+	var names []string
+	for _, t := range tags {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// ruleRequests turns rules back into the requests that create them.
+func ruleRequests(rules []RoutingRule) []RoutingRuleRequest {
+	var requests []RoutingRuleRequest
+	for _, rule := range rules {
+		requests = append(requests, RoutingRuleRequest{
+			Url:      rule.Url,
+			Platform: rule.Platform,
+			Language: rule.Language,
+			Country:  rule.Country,
+			FromHour: rule.FromHour,
+			ToHour:   rule.ToHour,
+			Timezone: rule.Timezone,
+		})
+	}
+	return requests
+}
+
+// variantRequests turns variants back into the requests that create them.
+func variantRequests(variants []Variant) []VariantRequest {
+	var requests []VariantRequest
+	for _, variant := range variants {
+		requests = append(requests, VariantRequest{
+			Name:   variant.Name,
+			Url:    variant.Url,
+			Weight: variant.Weight,
+		})
+	}
+	return requests
+}
+
+// exportWriter encodes links one by one. Flush hands buffered output to
+// the response; Close ends the document.
+type exportWriter interface {
+	Write(export LinkExport) error
+	Flush() error
+	Close() error
+}
+
+func newExportWriter(format string, w io.Writer) exportWriter {
+	switch format {
+	case FormatCsv:
+		return &csvExportWriter{writer: csv.NewWriter(w)}
+	case FormatNdjson:
+		return &ndjsonExportWriter{encoder: json.NewEncoder(w)}
+	default:
+		return &jsonExportWriter{w: w}
+	}
+}
+
+// csvExportWriter leaves out rules and variants, which do not fit in a
+// row; the json formats carry them.
+type csvExportWriter struct {
+	writer *csv.Writer
+	header bool
+}
+
+func (e *csvExportWriter) Write(export LinkExport) error {
+	if !e.header {
+		e.header = true
+		if err := e.writer.Write(exportColumns); err != nil {
+			return err
+		}
+	}
+
+	protected := ""
+	if export.Protected {
+		protected = "true"
+	}
+	record := []string{
+		export.Hash, export.ShortUrl, export.Url, export.Domain, export.Title, export.Description,
+		strings.Join(export.Tags, ","), export.Folder,
+		formatBool(export.Active), formatInt(export.RedirectCode), export.RedirectMode, export.ForwardQuery,
+		export.UtmSource, export.UtmMedium, export.UtmCampaign, export.UtmTerm, export.UtmContent,
+		export.IosDeepLink, export.AndroidDeepLink, export.IosStoreUrl, export.AndroidStoreUrl,
+		formatTime(export.StartsAt), formatTime(export.ExpiresAt), formatUint(export.MaxClicks),
+		protected, export.PasswordHash, strconv.FormatInt(export.Clicks, 10), formatTime(export.CreatedAt),
+	}
+	for i, cell := range record {
+		record[i] = escapeCsvFormula(cell)
+	}
+	e.writer.Write(record)
+	return e.writer.Error()
+}
+
+// csvFormulaPrefixes start cells that spreadsheets run as formulas.
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// escapeCsvFormula quotes cells a spreadsheet would run as a formula with
+// a leading apostrophe; titles come from fetched pages and can't be
+// trusted. unescapeCsvFormula undoes it on import.
+func escapeCsvFormula(cell string) string {
+	if cell != "" && strings.ContainsRune(csvFormulaPrefixes, rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+func unescapeCsvFormula(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune(csvFormulaPrefixes, rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}
+
+func (e *csvExportWriter) Flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvExportWriter) Close() error {
+	if !e.header {
+		e.header = true
+		e.writer.Write(exportColumns)
+	}
+	return e.Flush()
+}
+
+type ndjsonExportWriter struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonExportWriter) Write(export LinkExport) error {
+	return e.encoder.Encode(export)
+}
+
+func (e *ndjsonExportWriter) Flush() error {
+	return nil
+}
+
+func (e *ndjsonExportWriter) Close() error {
+	return nil
+}
+
+// jsonExportWriter writes a single array, one element at a time.
+type jsonExportWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonExportWriter) Write(export LinkExport) error {
+	data, err := json.Marshal(export)
+	if err != nil {
+		return err
+	}
+
+	separator := ",\n"
+	if e.count == 0 {
+		separator = "[\n"
+	}
+	e.count++
+	if _, err := io.WriteString(e.w, separator); err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonExportWriter) Flush() error {
+	return nil
+}
+
+func (e *jsonExportWriter) Close() error {
+	closing := "\n]\n"
+	if e.count == 0 {
+		closing = "[]\n"
+	}
+	_, err := io.WriteString(e.w, closing)
+	return err
+}
+
+func formatBool(value *bool) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatBool(*value)
+}
+
+func formatInt(value int) string {
+	if value == 0 {
+		return ""
+	}
+	return strconv.Itoa(value)
+}
+
+func formatUint(value *uint) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*value), 10)
+}
+
+func formatTime(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	return value.UTC().Format(time.RFC3339)
+}
+
+// parseCsvTime reads a time written by formatTime.
+func parseCsvTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
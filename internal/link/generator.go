@@ -0,0 +1,152 @@
+package link
+
+import (
+	"crypto/rand"
+	"demo/go-server/configs"
+	"errors"
+	"strings"
+)
+
+const (
+	HashStrategyRandom   = "random"
+	HashStrategySequence = "sequence"
+	base62Alphabet       = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+)
+
+// HashGenerator produces short codes for new links. Generate may be
+// called again for the same link when the previous code was taken.
+type HashGenerator interface {
+	Generate(link *Link) (string, error)
+}
+
+// NewHashGenerator builds the generator named by conf.HashStrategy. The
+// configured length is clamped to the alias limits, so generated codes
+// are never shorter or longer than a hand-picked alias may be.
+func NewHashGenerator(conf configs.LinkConfig, repo *LinkRepository) HashGenerator {
+	length := min(max(conf.HashLength, AliasMinLength), AliasMaxLength)
+
+	switch conf.HashStrategy {
+	case HashStrategySequence:
+		return &SequenceGenerator{
+			NextID:  repo.NextID,
+			Encoder: NewIDEncoder(conf.HashSalt, length),
+		}
+	default:
+		return NewBase62Generator(length)
+	}
+}
+
+// Base62Generator returns codes drawn uniformly from the base62 alphabet
+// using crypto/rand.
+type Base62Generator struct {
+	Length int
+}
+
+func NewBase62Generator(length int) *Base62Generator {
+	return &Base62Generator{Length: length}
+}
+
+func (g *Base62Generator) Generate(_ *Link) (string, error) {
+	return RandomBase62(g.Length)
+}
+
+// RandomBase62 returns n characters from the base62 alphabet. Bytes that
+// would bias the result towards the start of the alphabet are discarded.
+func RandomBase62(n int) (string, error) {
+	const limit = 256 - 256%len(base62Alphabet)
+
+	result := make([]byte, 0, n)
+	buf := make([]byte, n*2)
+	for len(result) < n {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+			result = append(result, base62Alphabet[int(b)%len(base62Alphabet)])
+			if len(result) == n {
+				break
+			}
+		}
+	}
+
+	return string(result), nil
+}
+
+// SequenceGenerator reserves the row id up front and derives the code
+// from it, so codes never collide with each other. The reserved id is
+// stored on the link and used on insert.
+type SequenceGenerator struct {
+	NextID  func() (uint, error)
+	Encoder *IDEncoder
+}
+
+func (g *SequenceGenerator) Generate(link *Link) (string, error) {
+	id, err := g.NextID()
+	if err != nil {
+		return "", err
+	}
+	link.ID = id
+	return g.Encoder.Encode(id), nil
+}
+
+// IDEncoder is a Hashids-style reversible encoding of ids: the base62
+// alphabet is shuffled with a salt and codes are padded to a minimum
+// length.
+type IDEncoder struct {
+	alphabet  string
+	minLength int
+}
+
+func NewIDEncoder(salt string, minLength int) *IDEncoder {
+	alphabet := []byte(base62Alphabet)
+	if salt != "" {
+		for i, j, v, p := len(alphabet)-1, 0, 0, 0; i > 0; i, v = i-1, v+1 {
+			v %= len(salt)
+			p += int(salt[v])
+			j = (int(salt[v]) + v + p) % i
+			alphabet[i], alphabet[j] = alphabet[j], alphabet[i]
+		}
+	}
+
+	return &IDEncoder{
+		alphabet:  string(alphabet),
+		minLength: minLength,
+	}
+}
+
+func (e *IDEncoder) Encode(id uint) string {
+	base := uint(len(e.alphabet))
+	var b []byte
+	for {
+		b = append(b, e.alphabet[id%base])
+		id /= base
+		if id == 0 {
+			break
+		}
+	}
+	for len(b) < e.minLength {
+		b = append(b, e.alphabet[0])
+	}
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+
+	return string(b)
+}
+
+func (e *IDEncoder) Decode(hash string) (uint, error) {
+	base := uint(len(e.alphabet))
+	var id uint
+	for _, r := range hash {
+		i := strings.IndexRune(e.alphabet, r)
+		if i < 0 {
+			return 0, errors.New(ErrInvalidHash)
+		}
+		id = id*base + uint(i)
+	}
+
+	return id, nil
+}
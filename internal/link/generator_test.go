@@ -0,0 +1,109 @@
+package link_test
+
+import (
+	"demo/go-server/configs"
+	"demo/go-server/internal/link"
+	"testing"
+)
+
+func TestBase62GeneratorCollisionRate(t *testing.T) {
+	const total = 100_000
+	generator := link.NewBase62Generator(7)
+
+	seen := make(map[string]struct{}, total)
+	chars := make(map[rune]struct{})
+	collisions := 0
+	for range total {
+		hash, err := generator.Generate(&link.Link{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hash) != 7 {
+			t.Fatalf("Got length %d expected %d", len(hash), 7)
+		}
+		if _, ok := seen[hash]; ok {
+			collisions++
+		}
+		seen[hash] = struct{}{}
+		for _, r := range hash {
+			chars[r] = struct{}{}
+		}
+	}
+
+	// 62^7 codes make a collision in 100k draws very unlikely (p < 0.2%).
+	if collisions > 1 {
+		t.Errorf("Got %d collisions in %d codes", collisions, total)
+	}
+	if len(chars) != 62 {
+		t.Errorf("Got %d distinct characters expected %d", len(chars), 62)
+	}
+}
+
+func TestSequenceGeneratorNeverCollides(t *testing.T) {
+	var next uint
+	generator := &link.SequenceGenerator{
+		NextID: func() (uint, error) {
+			next++
+			return next, nil
+		},
+		Encoder: link.NewIDEncoder("salt", 5),
+	}
+
+	seen := make(map[string]struct{})
+	for range 10_000 {
+		l := &link.Link{}
+		hash, err := generator.Generate(l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := seen[hash]; ok {
+			t.Fatalf("Hash %s generated twice", hash)
+		}
+		seen[hash] = struct{}{}
+		if l.ID != next {
+			t.Fatalf("Got id %d expected %d", l.ID, next)
+		}
+	}
+}
+
+func TestIDEncoderRoundTrip(t *testing.T) {
+	encoder := link.NewIDEncoder("salt", 5)
+	other := link.NewIDEncoder("pepper", 5)
+
+	for _, id := range []uint{0, 1, 61, 62, 12345, 1 << 40} {
+		hash := encoder.Encode(id)
+		if len(hash) < 5 {
+			t.Errorf("Hash %s shorter than %d", hash, 5)
+		}
+		decoded, err := encoder.Decode(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded != id {
+			t.Errorf("Got %d expected %d", decoded, id)
+		}
+		if id > 0 && other.Encode(id) == hash {
+			t.Errorf("Salt did not change hash %s", hash)
+		}
+	}
+}
+
+func TestHashGeneratorClampsLength(t *testing.T) {
+	cases := map[int]int{
+		0:   link.AliasMinLength,
+		1:   link.AliasMinLength,
+		7:   7,
+		100: link.AliasMaxLength,
+	}
+
+	for configured, expected := range cases {
+		generator := link.NewHashGenerator(configs.LinkConfig{HashLength: configured}, nil)
+		hash, err := generator.Generate(&link.Link{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hash) != expected {
+			t.Errorf("%d: got length %d expected %d", configured, len(hash), expected)
+		}
+	}
+}
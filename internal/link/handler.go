@@ -2,26 +2,56 @@ package link
 
 import (
 	"demo/go-server/configs"
+	"demo/go-server/internal/domain"
+	"demo/go-server/internal/folder"
+	"demo/go-server/internal/policy"
+	"demo/go-server/internal/tag"
+	"demo/go-server/internal/user"
+	"demo/go-server/pkg/di"
 	"demo/go-server/pkg/event"
+	"demo/go-server/pkg/geoip"
 	"demo/go-server/pkg/middleware"
+	"demo/go-server/pkg/qr"
 	"demo/go-server/pkg/request"
 	"demo/go-server/pkg/response"
+	"demo/go-server/pkg/useragent"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type LinkHandlerDeps struct {
-	LinkRepository *LinkRepository
-	EventBus       *event.EventBus
-	Config         *configs.Config
+	LinkRepository   *LinkRepository
+	UserRepository   di.IUserRepository
+	TagRepository    *tag.TagRepository
+	FolderRepository *folder.FolderRepository
+	DomainRepository *domain.DomainRepository
+	UrlPolicy        *policy.Policy
+	GeoIP            geoip.Reader
+	EventBus         *event.EventBus
+	QrLogo           *qr.Logo
+	Config           *configs.Config
 }
 
 type LinkHandler struct {
-	LinkRepository *LinkRepository
-	EventBus       *event.EventBus
+	LinkRepository   *LinkRepository
+	UserRepository   di.IUserRepository
+	TagRepository    *tag.TagRepository
+	FolderRepository *folder.FolderRepository
+	DomainRepository *domain.DomainRepository
+	UrlPolicy        *policy.Policy
+	GeoIP            geoip.Reader
+	EventBus         *event.EventBus
+	AttemptLimiter   *AttemptLimiter
+	HashGenerator    HashGenerator
+	QrLogo           *qr.Logo
+	Config           *configs.Config
 }
 
 type LinkResponse struct {
@@ -30,94 +60,259 @@ type LinkResponse struct {
 
 func NewLinkHandler(router *http.ServeMux, deps LinkHandlerDeps) {
 	handler := &LinkHandler{
-		LinkRepository: deps.LinkRepository,
-		EventBus:       deps.EventBus,
+		LinkRepository:   deps.LinkRepository,
+		UserRepository:   deps.UserRepository,
+		TagRepository:    deps.TagRepository,
+		FolderRepository: deps.FolderRepository,
+		DomainRepository: deps.DomainRepository,
+		UrlPolicy:        deps.UrlPolicy,
+		GeoIP:            deps.GeoIP,
+		EventBus:         deps.EventBus,
+		AttemptLimiter:   NewAttemptLimiter(deps.Config.Link.PasswordMaxAttempts, deps.Config.Link.PasswordLinkMaxAttempts, deps.Config.Link.PasswordAttemptWindow),
+		HashGenerator:    NewHashGenerator(deps.Config.Link, deps.LinkRepository),
+		QrLogo:           deps.QrLogo,
+		Config:           deps.Config,
 	}
 	router.Handle("POST /link", middleware.IsAuthed(handler.Create(), deps.Config))
+	router.Handle("POST /link/bulk", middleware.IsAuthed(handler.BulkCreate(), deps.Config))
+	router.Handle("DELETE /link/bulk", middleware.IsAuthed(handler.BulkDelete(), deps.Config))
 	router.Handle("PATCH /link/{id}", middleware.IsAuthed(handler.Update(), deps.Config))
 	router.Handle("DELETE /link/{id}", middleware.IsAuthed(handler.Delete(), deps.Config))
+	router.Handle("PATCH /link/{id}/status", middleware.IsAuthed(handler.UpdateStatus(), deps.Config))
+	router.Handle("GET /link/{id}/history", middleware.IsAuthed(handler.History(), deps.Config))
+	router.Handle("POST /link/{id}/revert/{rev}", middleware.IsAuthed(handler.Revert(), deps.Config))
+	router.Handle("GET /link/trash", middleware.IsAuthed(handler.Trash(), deps.Config))
+	router.Handle("POST /link/{id}/restore", middleware.IsAuthed(handler.Restore(), deps.Config))
+	router.Handle("DELETE /link/{id}/purge", middleware.IsAuthed(handler.Purge(), deps.Config))
+	router.Handle("POST /link/{id}/clone", middleware.IsAuthed(handler.Clone(), deps.Config))
+	router.Handle("POST /template", middleware.IsAuthed(handler.CreateTemplate(), deps.Config))
+	router.Handle("GET /template", middleware.IsAuthed(handler.GetTemplates(), deps.Config))
+	router.Handle("DELETE /template/{id}", middleware.IsAuthed(handler.DeleteTemplate(), deps.Config))
+	router.Handle("POST /template/{id}/generate", middleware.IsAuthed(handler.Generate(), deps.Config))
+	router.Handle("GET /link/{id}/health", middleware.IsAuthed(handler.Health(), deps.Config))
+	router.Handle("GET /link/{id}/qr", middleware.IsAuthed(handler.QrCode(), deps.Config))
+	router.Handle("GET /link/export", middleware.IsAuthed(handler.Export(), deps.Config))
+	router.Handle("POST /link/import", middleware.IsAuthed(handler.Import(), deps.Config))
+	router.Handle("GET /link/cache/stats", middleware.IsAuthed(handler.CacheStats(), deps.Config))
 	router.HandleFunc("GET /{hash}", handler.GoTo())
+	router.HandleFunc("POST /{hash}", handler.GoTo())
+	router.HandleFunc("GET /{hash}/qr", handler.PublicQrCode())
 	router.Handle("GET /link", middleware.IsAuthed(handler.GetAllLinks(), deps.Config))
 }
 
 func (handler *LinkHandler) Create() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
+		userID, ok := handler.currentUserID(w, req)
+		if !ok {
+			return
+		}
+
 		body, err := request.HandleBody[LinkCreateRequest](&w, req)
 
 		if err != nil {
 			return
 		}
 
-		link := NewLink(body.Url)
-		for {
-			existedLink, _ := handler.LinkRepository.GetByHash(link.Hash)
-			if existedLink == nil {
-				break
-			}
-			link.GenerateHash()
+		link, status, err := handler.buildLink(req, body, userID, nil)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
 		}
 
 		createdLink, err := handler.LinkRepository.Create(link)
-
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeCreateError(w, err)
 			return
 		}
 
+		go handler.EventBus.Publish(event.Event{
+			Type: event.LinkCreated,
+			Data: createdLink.ID,
+		})
+
+		handler.present(req, createdLink)
 		response.WriteResponse(w, createdLink, 201)
 	}
 }
 
 func (handler *LinkHandler) Update() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		email, ok := req.Context().Value(middleware.ContextEmailKey).(string)
-
-		if ok {
-			fmt.Println(email)
+		author, ok := handler.currentUser(w, req)
+		if !ok {
+			return
 		}
+		userID := author.ID
 
 		body, err := request.HandleBody[LinkUpdateRequest](&w, req)
 		if err != nil {
 			return
 		}
 
-		idString := req.PathValue("id")
-		id, err := strconv.ParseUint(idString, 10, 32)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		existedLink, ok := handler.ownedLinkFrom(w, req, userID, handler.LinkRepository.GetWithRelations)
+		if !ok {
 			return
 		}
 
-		link, err := handler.LinkRepository.Update(&Link{
-			Model: gorm.Model{ID: uint(id)},
-			Url:   body.Url,
-			Hash:  body.Hash,
+		if status, err := handler.checkUrl(req, body.Url); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		if status, err := handler.checkAppLinks(req, body.AppLinksRequest); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		if body.Hash == "" {
+			body.Hash = existedLink.Hash
+		} else if body.Hash != existedLink.Hash {
+			if status, err := handler.checkAlias(existedLink.DomainID, body.Hash); err != nil {
+				http.Error(w, err.Error(), status)
+				return
+			}
+		}
+
+		if body.FolderID != nil {
+			if status, err := handler.checkFolder(*body.FolderID, userID); err != nil {
+				http.Error(w, err.Error(), status)
+				return
+			}
+		}
+
+		var rules []RoutingRule
+		if body.Rules != nil {
+			var status int
+			rules, status, err = handler.buildRules(req, *body.Rules)
+			if err != nil {
+				http.Error(w, err.Error(), status)
+				return
+			}
+		}
+
+		var variants []Variant
+		if body.Variants != nil {
+			var status int
+			variants, status, err = handler.buildVariants(req, *body.Variants)
+			if err != nil {
+				http.Error(w, err.Error(), status)
+				return
+			}
+		}
+
+		var tags []tag.Tag
+		if body.Tags != nil {
+			tags, err = handler.TagRepository.GetOrCreate(userID, *body.Tags)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		var link *Link
+		err = handler.LinkRepository.Transaction(func(repo *LinkRepository) error {
+			link, err = repo.Update(&Link{
+				Model:        gorm.Model{ID: existedLink.ID},
+				Url:          body.Url,
+				Hash:         body.Hash,
+				Title:        body.Title,
+				Description:  body.Description,
+				FolderID:     body.FolderID,
+				RedirectCode: body.RedirectCode,
+				RedirectMode: body.RedirectMode,
+				ForwardQuery: body.ForwardQuery,
+				UtmSource:    body.UtmSource,
+				UtmMedium:    body.UtmMedium,
+				UtmCampaign:  body.UtmCampaign,
+				UtmTerm:      body.UtmTerm,
+				UtmContent:   body.UtmContent,
+				AppLinks:     body.appLinks(),
+				UserID:       userID,
+			})
+			if err != nil {
+				return err
+			}
+
+			link.Rules, link.Variants, link.Tags = existedLink.Rules, existedLink.Variants, existedLink.Tags
+			if body.Rules != nil {
+				if err := repo.ReplaceRules(link, rules); err != nil {
+					return err
+				}
+				link.Rules = rules
+			}
+			if body.Variants != nil {
+				if err := repo.ReplaceVariants(link, variants); err != nil {
+					return err
+				}
+				link.Variants = variants
+			}
+			if body.Tags != nil {
+				if err := repo.ReplaceTags(link, tags); err != nil {
+					return err
+				}
+				link.Tags = tags
+			}
+
+			return recordRevision(repo, author, existedLink, link, body.Reason)
 		})
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
+		handler.present(req, link)
 		response.WriteResponse(w, link, 201)
 	}
 }
 
-func (handler *LinkHandler) Delete() http.HandlerFunc {
+func (handler *LinkHandler) UpdateStatus() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		idString := req.PathValue("id")
-		id, err := strconv.ParseUint(idString, 10, 32)
+		author, ok := handler.currentUser(w, req)
+		if !ok {
+			return
+		}
 
+		body, err := request.HandleBody[LinkStatusRequest](&w, req)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		if _, err := handler.LinkRepository.GetById(uint(id)); err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+		link, ok := handler.ownedLinkFrom(w, req, author.ID, handler.LinkRepository.GetWithRelations)
+		if !ok {
 			return
 		}
 
-		if err := handler.LinkRepository.Delete(uint(id)); err != nil {
+		before := *link
+		link.Active = *body.Active
+		link.StartsAt = body.StartsAt
+		err = handler.LinkRepository.Transaction(func(repo *LinkRepository) error {
+			if err := repo.UpdateStatus(link); err != nil {
+				return err
+			}
+			return recordRevision(repo, author, &before, link, body.Reason)
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		handler.present(req, link)
+		response.WriteResponse(w, link, 200)
+	}
+}
+
+func (handler *LinkHandler) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, ok := handler.currentUserID(w, req)
+		if !ok {
+			return
+		}
+
+		link, ok := handler.ownedLink(w, req, userID)
+		if !ok {
+			return
+		}
+
+		if err := handler.LinkRepository.Delete(link.ID, userID); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -128,50 +323,536 @@ func (handler *LinkHandler) Delete() http.HandlerFunc {
 func (handler *LinkHandler) GoTo() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		hash := req.PathValue("hash")
-		link, err := handler.LinkRepository.GetByHash(hash)
+		link, err := handler.LinkRepository.GetByHash(handler.requestDomainID(req), hash)
 
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
 
+		now := time.Now()
+		if !link.IsLive(now) {
+			handler.publishRefused(link)
+			handler.writeInactive(w, req)
+			return
+		}
+
+		if link.IsExpired(now) {
+			handler.refuse(w, link)
+			return
+		}
+
+		if link.IsProtected() && !handler.unlock(w, req, link) {
+			return
+		}
+
+		if link.MaxClicks != nil && !handler.LinkRepository.ConsumeClick(link.ID) {
+			handler.refuse(w, link)
+			return
+		}
+
+		visit := handler.newVisit(req, link, now)
+		destination := link.Url
+		var rule *RoutingRule
+		if len(link.Rules) > 0 {
+			rule = link.MatchRule(handler.visitor(req, link, now))
+		}
+		if rule != nil {
+			destination = rule.Url
+		} else if variant := stickyVariant(w, req, link); variant != nil {
+			destination = variant.Url
+			visit.Variant = variant.Name
+		}
+
 		go handler.EventBus.Publish(event.Event{
 			Type: event.LinkVisited,
-			Data: link.ID,
+			Data: visit,
 		})
-		http.Redirect(w, req, link.Url, http.StatusTemporaryRedirect)
+		destination = mergeQuery(destination, link.UtmParams())
+		destination = link.forwardQuery(destination, req.URL.Query())
+		if handler.openApp(w, req, link, destination) {
+			return
+		}
+		redirect(w, req, link, destination)
 	}
 }
 
+// GetAllLinks pages through the caller's links. Pass the "next" value
+// of a page as cursor to get the following one; the total is only
+// counted when asked for with count=true.
 func (handler *LinkHandler) GetAllLinks() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		limit, offset := 0, 0
+		userID, ok := handler.currentUserID(w, req)
+		if !ok {
+			return
+		}
 
-		limitStr := req.URL.Query().Get("limit")
-		if limitStr != "" {
-			var err error
-			limit, err = strconv.Atoi(limitStr)
-			if err != nil {
-				http.Error(w, "Invalid limit", http.StatusBadRequest)
-				return
-			}
+		filter, err := handler.listFilter(req, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
-		offsetStr := req.URL.Query().Get("offset")
-		if offsetStr != "" {
-			var err error
-			offset, err = strconv.Atoi(offsetStr)
-			if err != nil {
-				http.Error(w, "Invalid offset", http.StatusBadRequest)
-				return
+		handler.writeLinkPage(w, req, filter)
+	}
+}
+
+// listFilter reads paging, sorting and filter query parameters.
+func (handler *LinkHandler) listFilter(req *http.Request, userID uint) (LinkFilter, error) {
+	query := req.URL.Query()
+	filter := LinkFilter{
+		UserID:   userID,
+		Tag:      query.Get("tag"),
+		Campaign: query.Get("campaign"),
+		Query:    query.Get("q"),
+		Domain:   strings.TrimSpace(query.Get("domain")),
+		Sort:     SortCreatedAt,
+		Limit:    handler.Config.Link.PageSize,
+	}
+
+	if query.Has("offset") {
+		return filter, errors.New(ErrOffsetRemoved)
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > handler.Config.Link.MaxPageSize {
+			return filter, fmt.Errorf(ErrInvalidLimit, handler.Config.Link.MaxPageSize)
+		}
+		filter.Limit = limit
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		if _, ok := linkSortColumns[sort]; !ok {
+			return filter, errors.New(ErrInvalidSort)
+		}
+		filter.Sort = sort
+	}
+
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		return filter, errors.New(ErrInvalidOrder)
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		after, err := decodeLinkCursor(cursor, filter.Sort, filter.Desc)
+		if err != nil {
+			return filter, err
+		}
+		filter.After = after
+	}
+
+	switch health := query.Get("health"); health {
+	case "", HealthBroken, HealthHealthy, HealthUnchecked:
+		filter.Health = health
+	default:
+		return filter, errors.New(ErrInvalidHealth)
+	}
+
+	if folderStr := query.Get("folder"); folderStr != "" {
+		folderID, err := strconv.ParseUint(folderStr, 10, 32)
+		if err != nil {
+			return filter, errors.New("Invalid folder")
+		}
+		id := uint(folderID)
+		filter.FolderID = &id
+	}
+
+	var err error
+	if filter.CreatedFrom, err = parseDateParam(query.Get("created_from"), "created_from", false); err != nil {
+		return filter, err
+	}
+	if filter.CreatedTo, err = parseDateParam(query.Get("created_to"), "created_to", true); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+// writeLinkPage answers with one page of links and the cursor of the
+// next page, if there is one.
+func (handler *LinkHandler) writeLinkPage(w http.ResponseWriter, req *http.Request, filter LinkFilter) {
+	pageSize := filter.Limit
+	filter.Limit++
+	links, err := handler.LinkRepository.GetAll(filter)
+	if errors.Is(err, errCursorValue) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := GetAllLinksResponse{Links: links}
+	if pageSize > 0 && len(links) > pageSize {
+		data.Links = links[:pageSize]
+		data.Next = newLinkCursor(&data.Links[pageSize-1], filter.Sort, filter.Desc).Encode()
+	}
+	page := make([]*Link, len(data.Links))
+	for i := range data.Links {
+		page[i] = &data.Links[i]
+	}
+	handler.present(req, page...)
+	if req.URL.Query().Get("count") == "true" {
+		count := handler.LinkRepository.Count(filter)
+		data.Count = &count
+	}
+
+	response.WriteResponse(w, data, 200)
+}
+
+// parseDateParam accepts an RFC 3339 time or a plain date. A plain date
+// used as an upper bound includes the whole day.
+func parseDateParam(value, name string, upper bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, fmt.Errorf(ErrInvalidDate, name)
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+func (handler *LinkHandler) visitor(req *http.Request, link *Link, now time.Time) *Visitor {
+	visitor := &Visitor{
+		Platform:  useragent.Platform(req.UserAgent()),
+		Languages: request.Languages(req),
+		Time:      now,
+	}
+	if link.NeedsCountry() && handler.GeoIP != nil {
+		visitor.Country = handler.GeoIP.Country(request.ClientIP(req, handler.Config.TrustProxy))
+	}
+
+	return visitor
+}
+
+// newVisit describes a visit of link for the LinkVisited event.
+func (handler *LinkHandler) newVisit(req *http.Request, link *Link, now time.Time) event.Visit {
+	visit := event.Visit{
+		LinkID:    link.ID,
+		Time:      now,
+		Referrer:  req.Referer(),
+		UserAgent: req.UserAgent(),
+		IP:        request.ClientIP(req, handler.Config.TrustProxy),
+	}
+	if languages := request.Languages(req); len(languages) > 0 {
+		visit.Language = languages[0]
+	}
+
+	return visit
+}
+
+func (handler *LinkHandler) refuse(w http.ResponseWriter, link *Link) {
+	handler.publishRefused(link)
+	http.Error(w, ErrLinkExpired, http.StatusGone)
+}
+
+func (handler *LinkHandler) publishRefused(link *Link) {
+	go handler.EventBus.Publish(event.Event{
+		Type: event.LinkRefused,
+		Data: event.Visit{LinkID: link.ID},
+	})
+}
+
+// unlock verifies the password of a protected link, taken from the
+// PasswordHeader or a submitted form, and serves the unlock form otherwise.
+func (handler *LinkHandler) unlock(w http.ResponseWriter, req *http.Request, link *Link) bool {
+	password := req.Header.Get(PasswordHeader)
+	if password == "" && req.Method == http.MethodPost {
+		password = req.FormValue("password")
+	}
+
+	if password == "" {
+		writeUnlockForm(w, link.Hash, "", http.StatusUnauthorized)
+		return false
+	}
+
+	client := request.ClientIP(req, handler.Config.TrustProxy)
+	if !handler.AttemptLimiter.Allow(link.ID, client) {
+		writeUnlockForm(w, link.Hash, ErrTooManyTries, http.StatusTooManyRequests)
+		return false
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(link.Password), []byte(password)); err != nil {
+		handler.AttemptLimiter.Fail(link.ID, client)
+		writeUnlockForm(w, link.Hash, ErrWrongPassword, http.StatusUnauthorized)
+		return false
+	}
+
+	handler.AttemptLimiter.Reset(link.ID, client)
+	return true
+}
+
+// currentUserID resolves the authenticated caller from the email that
+// IsAuthed stores in the request context.
+func (handler *LinkHandler) currentUserID(w http.ResponseWriter, req *http.Request) (uint, bool) {
+	existedUser, ok := handler.currentUser(w, req)
+	if !ok {
+		return 0, false
+	}
+
+	return existedUser.ID, true
+}
+
+func (handler *LinkHandler) currentUser(w http.ResponseWriter, req *http.Request) (*user.User, bool) {
+	existedUser, err := user.FromRequest(req, handler.UserRepository)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+
+	return existedUser, true
+}
+
+// buildLink turns a create request into a new link owned by userID.
+// Hashes in taken are treated as already used, which lets batch callers
+// avoid collisions inside the batch. On failure the returned status code
+// tells the caller how to report the error.
+func (handler *LinkHandler) buildLink(req *http.Request, body *LinkCreateRequest, userID uint, taken map[string]struct{}) (*Link, int, error) {
+	link, status, err := handler.prepareLink(req, body, userID)
+	if err != nil {
+		return nil, status, err
+	}
+
+	if status, err := handler.assignHash(link, body.Alias, taken); err != nil {
+		return nil, status, err
+	}
+
+	return link, 0, nil
+}
+
+// prepareLink does the work of buildLink short of giving the link a hash.
+func (handler *LinkHandler) prepareLink(req *http.Request, body *LinkCreateRequest, userID uint) (*Link, int, error) {
+	if status, err := handler.checkUrl(req, body.Url); err != nil {
+		return nil, status, err
+	}
+	if status, err := handler.checkAppLinks(req, body.AppLinksRequest); err != nil {
+		return nil, status, err
+	}
+
+	link := NewLink(body.Url, userID)
+	link.Title = body.Title
+	link.Description = body.Description
+	link.StartsAt = body.StartsAt
+	link.RedirectCode = body.RedirectCode
+	link.RedirectMode = body.RedirectMode
+	link.ForwardQuery = body.ForwardQuery
+	link.UtmSource = body.UtmSource
+	link.UtmMedium = body.UtmMedium
+	link.UtmCampaign = body.UtmCampaign
+	link.UtmTerm = body.UtmTerm
+	link.UtmContent = body.UtmContent
+	link.AppLinks = body.appLinks()
+	link.ExpiresAt = body.ExpiresAt
+	link.MaxClicks = body.MaxClicks
+
+	if body.FolderID != nil {
+		if status, err := handler.checkFolder(*body.FolderID, userID); err != nil {
+			return nil, status, err
+		}
+		link.FolderID = body.FolderID
+	}
+
+	if body.DomainID != nil {
+		if status, err := handler.checkDomain(*body.DomainID, userID); err != nil {
+			return nil, status, err
+		}
+		link.DomainID = body.DomainID
+	}
+
+	if len(body.Rules) > 0 {
+		rules, status, err := handler.buildRules(req, body.Rules)
+		if err != nil {
+			return nil, status, err
+		}
+		link.Rules = rules
+	}
+
+	if len(body.Variants) > 0 {
+		variants, status, err := handler.buildVariants(req, body.Variants)
+		if err != nil {
+			return nil, status, err
+		}
+		link.Variants = variants
+	}
+
+	// The repository looks the tags up when it stores the link, in the
+	// same transaction.
+	for _, name := range body.Tags {
+		link.Tags = append(link.Tags, tag.Tag{Name: name, UserID: userID})
+	}
+
+	if body.Password != "" {
+		var err error
+		link.Password, err = hashPassword(body.Password)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+
+	return link, 0, nil
+}
+
+// assignHash gives link the alias, when one is asked for, or a fresh
+// generated hash. Hashes in taken count as used.
+func (handler *LinkHandler) assignHash(link *Link, alias string, taken map[string]struct{}) (int, error) {
+	if alias != "" {
+		if _, ok := taken[hashKey(link.DomainID, alias)]; ok {
+			return http.StatusConflict, errors.New(ErrAliasTaken)
+		}
+		if status, err := handler.checkAlias(link.DomainID, alias); err != nil {
+			return status, err
+		}
+		link.Hash = alias
+		return 0, nil
+	}
+
+	for {
+		hash, err := handler.HashGenerator.Generate(link)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		link.Hash = hash
+
+		_, inBatch := taken[hashKey(link.DomainID, link.Hash)]
+		if !inBatch && !handler.LinkRepository.HashExists(link.DomainID, link.Hash) {
+			return 0, nil
+		}
+	}
+}
+
+// buildRules checks every rule destination against the policy, loads
+// the rule time zones and turns the requests into rules in the order
+// given.
+func (handler *LinkHandler) buildRules(req *http.Request, requests []RoutingRuleRequest) ([]RoutingRule, int, error) {
+	rules := make([]RoutingRule, len(requests))
+	for i, r := range requests {
+		if status, err := handler.checkUrl(req, r.Url); err != nil {
+			return nil, status, err
+		}
+		if r.Timezone != "" {
+			if _, err := loadLocation(r.Timezone); err != nil {
+				return nil, http.StatusUnprocessableEntity, fmt.Errorf(ErrTimezone, r.Timezone)
 			}
 		}
+		rules[i] = RoutingRule{
+			Position: i,
+			Platform: r.Platform,
+			Language: strings.ToLower(r.Language),
+			Country:  strings.ToUpper(r.Country),
+			FromHour: r.FromHour,
+			ToHour:   r.ToHour,
+			Timezone: r.Timezone,
+			Url:      r.Url,
+		}
+	}
+
+	return rules, 0, nil
+}
+
+// buildVariants checks every variant destination against the policy.
+func (handler *LinkHandler) buildVariants(req *http.Request, requests []VariantRequest) ([]Variant, int, error) {
+	variants := make([]Variant, len(requests))
+	for i, r := range requests {
+		if status, err := handler.checkUrl(req, r.Url); err != nil {
+			return nil, status, err
+		}
+		variants[i] = Variant{
+			Name:   r.Name,
+			Url:    r.Url,
+			Weight: r.Weight,
+		}
+	}
+
+	return variants, 0, nil
+}
+
+// checkUrl runs the destination policy, returning 422 when the url is
+// refused.
+func (handler *LinkHandler) checkUrl(req *http.Request, url string) (int, error) {
+	ownHosts := []string{req.Host}
+	if host, ok := handler.isCustomHost(url); ok {
+		ownHosts = append(ownHosts, host)
+	}
+	if err := handler.UrlPolicy.Check(req.Context(), url, ownHosts...); err != nil {
+		return http.StatusUnprocessableEntity, err
+	}
+
+	return 0, nil
+}
+
+// checkAlias applies the vanity alias rules and makes sure the alias is
+// still free on the domain, returning 422 or 409 otherwise.
+func (handler *LinkHandler) checkAlias(domainID *uint, alias string) (int, error) {
+	if err := ValidateAlias(alias); err != nil {
+		return http.StatusUnprocessableEntity, err
+	}
+
+	if handler.LinkRepository.HashExists(domainID, alias) {
+		return http.StatusConflict, errors.New(ErrAliasTaken)
+	}
+
+	return 0, nil
+}
+
+// writeCreateError answers a failed insert. checkAlias runs before the
+// insert, so a hash taken in between only shows up as a unique violation.
+func writeCreateError(w http.ResponseWriter, err error) {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		http.Error(w, ErrAliasTaken, http.StatusConflict)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// checkFolder makes sure the folder exists and belongs to userID.
+func (handler *LinkHandler) checkFolder(folderID, userID uint) (int, error) {
+	if _, err := handler.FolderRepository.GetById(folderID, userID); err != nil {
+		return http.StatusUnprocessableEntity, errors.New(ErrUnknownFolder)
+	}
+
+	return 0, nil
+}
 
-		links := handler.LinkRepository.GetAll(limit, offset)
-		count := handler.LinkRepository.Count()
-		response.WriteResponse(w, GetAllLinksResponse{
-			Links: links,
-			Count: count,
-		}, 200)
+// ownedLink loads the link from the {id} path value and makes sure it
+// belongs to userID, writing 400/404/403 otherwise.
+func (handler *LinkHandler) ownedLink(w http.ResponseWriter, req *http.Request, userID uint) (*Link, bool) {
+	return handler.ownedLinkFrom(w, req, userID, handler.LinkRepository.GetById)
+}
+
+func (handler *LinkHandler) ownedLinkFrom(w http.ResponseWriter, req *http.Request, userID uint, lookup func(id uint) (*Link, error)) (*Link, bool) {
+	idString := req.PathValue("id")
+	id, err := strconv.ParseUint(idString, 10, 32)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	link, err := lookup(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, ErrLinkNotFound, http.StatusNotFound)
+		return nil, false
 	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if link.UserID != userID {
+		http.Error(w, ErrForbidden, http.StatusForbidden)
+		return nil, false
+	}
+
+	return link, true
 }
@@ -0,0 +1,1137 @@
+package link_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"demo/go-server/configs"
+	"demo/go-server/internal/domain"
+	"demo/go-server/internal/link"
+	"demo/go-server/internal/policy"
+	"demo/go-server/internal/tag"
+	"demo/go-server/internal/user"
+	"demo/go-server/pkg/db"
+	"demo/go-server/pkg/event"
+	"demo/go-server/pkg/middleware"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type MockUserRepository struct {
+}
+
+func (repo *MockUserRepository) Create(u *user.User) (*user.User, error) {
+	return u, nil
+}
+
+func (repo *MockUserRepository) GetByEmail(email string) (*user.User, error) {
+	return &user.User{Model: gorm.Model{ID: 1}, Email: email}, nil
+}
+
+func bootstrap() (*link.LinkHandler, sqlmock.Sqlmock, error) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		return nil, nil, err
+	}
+	gormDb, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: database,
+	}), &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	handler := link.LinkHandler{
+		LinkRepository: link.NewLinkRepository(&db.Db{
+			DB: gormDb,
+		}),
+		UserRepository: &MockUserRepository{},
+		TagRepository: tag.NewTagRepository(&db.Db{
+			DB: gormDb,
+		}),
+		EventBus:       event.NewEventBus(),
+		AttemptLimiter: link.NewAttemptLimiter(1, 10, time.Minute),
+		HashGenerator:  link.NewBase62Generator(7),
+		UrlPolicy: policy.NewPolicy(configs.PolicyConfig{
+			AllowedSchemes: []string{"http", "https"},
+		}, nil),
+		Config: &configs.Config{
+			Link: configs.LinkConfig{
+				BulkMaxItems:  10,
+				BulkMaxBytes:  1 << 20,
+				PageSize:      2,
+				MaxPageSize:   100,
+				ImportMaxRows: 100,
+			},
+		},
+	}
+	return &handler, mock, nil
+}
+
+// expectLinkByHash queues the link row and the empty routing rules and
+// variants that GetByHash preloads.
+func expectLinkByHash(mock sqlmock.Sqlmock, rows *sqlmock.Rows) {
+	expectLinkWithVariants(mock, rows, sqlmock.NewRows([]string{"id"}))
+}
+
+func expectLinkWithVariants(mock sqlmock.Sqlmock, rows *sqlmock.Rows, variants *sqlmock.Rows) {
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT").WillReturnRows(variants)
+}
+
+// expectLinkWithRelations queues the link row with the rules, tags and
+// variants that GetWithRelations preloads. Tags are always empty.
+func expectLinkWithRelations(mock sqlmock.Sqlmock, rows *sqlmock.Rows, rules *sqlmock.Rows, variants *sqlmock.Rows) {
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+	mock.ExpectQuery("SELECT").WillReturnRows(rules)
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"link_id", "tag_id"}))
+	mock.ExpectQuery("SELECT").WillReturnRows(variants)
+}
+
+// linkInsertColumns lists the columns of an INSERT into links, in order.
+var linkInsertColumns = []string{
+	"created_at", "updated_at", "deleted_at", "url", "hash", "domain_id", "title", "description",
+	"image", "favicon", "user_id", "folder_id", "active", "redirect_code", "redirect_mode",
+	"forward_query", "utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content",
+	"ios_deep_link", "android_deep_link", "ios_store_url", "android_store_url", "starts_at",
+	"expires_at", "max_clicks", "password", "clicks",
+}
+
+// insertArgs matches the arguments of an INSERT into links, checking only
+// the columns in values.
+func insertArgs(values map[string]driver.Value) []driver.Value {
+	args := make([]driver.Value, len(linkInsertColumns))
+	for i, column := range linkInsertColumns {
+		if value, ok := values[column]; ok {
+			args[i] = value
+		} else {
+			args[i] = sqlmock.AnyArg()
+		}
+	}
+	return args
+}
+
+func authedRequest(method, target string) *http.Request {
+	return authedRequestWithBody(method, target, nil)
+}
+
+func authedRequestWithBody(method, target string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	ctx := context.WithValue(req.Context(), middleware.ContextEmailKey, "a@a.com")
+	return req.WithContext(ctx)
+}
+
+func TestDeleteForeignLink(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	rows := sqlmock.NewRows([]string{"id", "url", "hash", "user_id"}).
+		AddRow(5, "https://a.com", "qwerty", 2)
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+
+	wr := httptest.NewRecorder()
+	req := authedRequest(http.MethodDelete, "/link/5")
+	req.SetPathValue("id", "5")
+
+	handler.Delete()(wr, req)
+	if wr.Code != http.StatusForbidden {
+		t.Errorf("Got %d expected %d", wr.Code, http.StatusForbidden)
+	}
+}
+
+func TestDeleteMissingLink(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	wr := httptest.NewRecorder()
+	req := authedRequest(http.MethodDelete, "/link/5")
+	req.SetPathValue("id", "5")
+
+	handler.Delete()(wr, req)
+	if wr.Code != http.StatusNotFound {
+		t.Errorf("Got %d expected %d", wr.Code, http.StatusNotFound)
+	}
+}
+
+func TestGoToExpiredLink(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	rows := sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active", "expires_at"}).
+		AddRow(5, "https://a.com", "qwerty", 1, true, time.Now().Add(-time.Hour))
+	expectLinkByHash(mock, rows)
+
+	wr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/qwerty", nil)
+	req.SetPathValue("hash", "qwerty")
+
+	handler.GoTo()(wr, req)
+	if wr.Code != http.StatusGone {
+		t.Errorf("Got %d expected %d", wr.Code, http.StatusGone)
+	}
+}
+
+func TestGoToProtectedLink(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	hashed, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	for range 3 {
+		rows := sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active", "password"}).
+			AddRow(5, "https://a.com", "qwerty", 1, true, string(hashed))
+		expectLinkByHash(mock, rows)
+	}
+
+	expected := []int{http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusTooManyRequests}
+	for _, code := range expected {
+		wr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/qwerty", nil)
+		req.SetPathValue("hash", "qwerty")
+		req.Header.Set(link.PasswordHeader, "wrong")
+
+		handler.GoTo()(wr, req)
+		if wr.Code != code {
+			t.Errorf("Got %d expected %d", wr.Code, code)
+		}
+	}
+}
+
+func TestBulkCreateRejectsInvalidRows(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	csv := "url,alias\nhttps://a.com,\nnot-a-url,\n"
+	wr := httptest.NewRecorder()
+	req := authedRequestWithBody(http.MethodPost, "/link/bulk", strings.NewReader(csv))
+	req.Header.Set("Content-Type", "text/csv")
+
+	handler.BulkCreate()(wr, req)
+	if wr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Got %d expected %d", wr.Code, http.StatusUnprocessableEntity)
+	}
+
+	var data link.LinkBulkCreateResponse
+	if err := json.Unmarshal(wr.Body.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Failed != 1 || data.Created != 0 {
+		t.Errorf("Got %d failed and %d created", data.Failed, data.Created)
+	}
+	if data.Results[1].Error == "" {
+		t.Errorf("Expected an error for row 1")
+	}
+}
+
+func TestBulkCreateLimits(t *testing.T) {
+	handler, _, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// Reading stops after one item more than allowed.
+	csv := "url\n" + strings.Repeat("https://shop.test\n", 50)
+	wr := httptest.NewRecorder()
+	req := authedRequestWithBody(http.MethodPost, "/link/bulk", strings.NewReader(csv))
+	req.Header.Set("Content-Type", "text/csv")
+	handler.BulkCreate()(wr, req)
+	if wr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Got %d expected %d", wr.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	handler.Config.Link.BulkMaxBytes = 64
+	body := `[{"url":"https://shop.test/` + strings.Repeat("a", 100) + `"}]`
+	wr = httptest.NewRecorder()
+	req = authedRequestWithBody(http.MethodPost, "/link/bulk", strings.NewReader(body))
+	handler.BulkCreate()(wr, req)
+	if wr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Got %d expected %d: %s", wr.Code, http.StatusRequestEntityTooLarge, wr.Body.String())
+	}
+}
+
+func TestBulkCreateTagsInTransaction(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "tags" .* ON CONFLICT DO NOTHING`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`"tags" WHERE \(user_id = \$1 and name in \(\$2\)\)`).WithArgs(1, "promo").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "user_id"}).AddRow(3, "promo", 1))
+	mock.ExpectQuery(`INSERT INTO "links"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5).AddRow(6))
+	mock.ExpectQuery(`INSERT INTO "tags"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectExec(`INSERT INTO "link_tags"`).WithArgs(5, 3, 6, 3).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	body := `[{"url":"https://shop.test/a","tags":["promo"]},{"url":"https://shop.test/b","tags":["promo"]}]`
+	wr := httptest.NewRecorder()
+	req := authedRequestWithBody(http.MethodPost, "/link/bulk", strings.NewReader(body))
+	handler.BulkCreate()(wr, req)
+	if wr.Code != http.StatusCreated {
+		t.Fatalf("Got %d expected %d: %s", wr.Code, http.StatusCreated, wr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGoToScheduledLink(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	handler.Config.Link.InactiveMode = link.InactiveModeRedirect
+	handler.Config.Link.InactiveUrl = "https://fallback.com"
+
+	rows := sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active", "starts_at"}).
+		AddRow(5, "https://a.com", "qwerty", 1, true, time.Now().Add(time.Hour))
+	expectLinkByHash(mock, rows)
+
+	wr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/qwerty", nil)
+	req.SetPathValue("hash", "qwerty")
+
+	handler.GoTo()(wr, req)
+	if wr.Code != http.StatusFound {
+		t.Fatalf("Got %d expected %d", wr.Code, http.StatusFound)
+	}
+	if location := wr.Header().Get("Location"); location != "https://fallback.com" {
+		t.Errorf("Got location %s", location)
+	}
+}
+
+func TestGoToInactivePage(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	handler.Config.Link.InactiveMode = link.InactiveModePage
+	handler.Config.Link.InactiveMessage = "Back soon."
+
+	rows := sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active"}).
+		AddRow(5, "https://a.com", "qwerty", 1, false)
+	expectLinkByHash(mock, rows)
+
+	wr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/qwerty", nil)
+	req.SetPathValue("hash", "qwerty")
+
+	handler.GoTo()(wr, req)
+	if wr.Code != http.StatusNotFound {
+		t.Fatalf("Got %d expected %d", wr.Code, http.StatusNotFound)
+	}
+	if !strings.Contains(wr.Body.String(), "Back soon.") {
+		t.Errorf("Got body %s", wr.Body.String())
+	}
+}
+
+func TestGoToStickyVariant(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	for range 2 {
+		rows := sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active"}).
+			AddRow(5, "https://a.com", "qwerty", 1, true)
+		variants := sqlmock.NewRows([]string{"id", "link_id", "name", "url", "weight"}).
+			AddRow(1, 5, "spring sale; v1", "https://a.com/1", 1).
+			AddRow(2, 5, "spring sale; v2", "https://a.com/2", 1)
+		expectLinkWithVariants(mock, rows, variants)
+	}
+
+	wr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/qwerty", nil)
+	req.SetPathValue("hash", "qwerty")
+	handler.GoTo()(wr, req)
+
+	cookies := wr.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("Got %d cookies expected 1", len(cookies))
+	}
+	location := wr.Header().Get("Location")
+	if location != "https://a.com/"+cookies[0].Value {
+		t.Fatalf("Got location %s for variant %s", location, cookies[0].Value)
+	}
+
+	wr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/qwerty", nil)
+	req.SetPathValue("hash", "qwerty")
+	req.AddCookie(cookies[0])
+	handler.GoTo()(wr, req)
+
+	if got := wr.Header().Get("Location"); got != location {
+		t.Errorf("Got location %s expected sticky %s", got, location)
+	}
+}
+
+func TestGoToRedirectSettings(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	rows := sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active", "redirect_code", "forward_query"}).
+		AddRow(5, "https://a.com/page?ref=short#top", "qwerty", 1, true, 301, link.ForwardQueryUtm)
+	expectLinkByHash(mock, rows)
+	rows = sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active", "redirect_mode"}).
+		AddRow(5, "https://a.com/page", "qwerty", 1, true, link.RedirectModeMeta)
+	expectLinkByHash(mock, rows)
+
+	wr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/qwerty?utm_source=mail&ref=other&foo=bar", nil)
+	req.SetPathValue("hash", "qwerty")
+	handler.GoTo()(wr, req)
+
+	if wr.Code != http.StatusMovedPermanently {
+		t.Errorf("Got %d expected %d", wr.Code, http.StatusMovedPermanently)
+	}
+	expected := "https://a.com/page?ref=short&utm_source=mail#top"
+	if location := wr.Header().Get("Location"); location != expected {
+		t.Errorf("Got location %s expected %s", location, expected)
+	}
+
+	wr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/qwerty", nil)
+	req.SetPathValue("hash", "qwerty")
+	handler.GoTo()(wr, req)
+
+	if wr.Code != http.StatusOK {
+		t.Errorf("Got %d expected %d", wr.Code, http.StatusOK)
+	}
+	if !strings.Contains(wr.Body.String(), `http-equiv="refresh" content="0;url=https://a.com/page"`) {
+		t.Errorf("Meta refresh missing from %s", wr.Body.String())
+	}
+}
+
+func TestGoToMergesUtmParams(t *testing.T) {
+	cases := []struct {
+		destination string
+		expected    string
+	}{
+		{"https://a.com/?utm_source=keep&id=7", "https://a.com/?utm_source=keep&id=7&utm_campaign=spring"},
+		{"https://a.com/p?x=1;y=2", "https://a.com/p?x=1;y=2&utm_campaign=spring&utm_source=newsletter"},
+		{"https://a.com/p?flag", "https://a.com/p?flag&utm_campaign=spring&utm_source=newsletter"},
+		{"https://a.com/p?", "https://a.com/p?utm_campaign=spring&utm_source=newsletter"},
+	}
+
+	for _, c := range cases {
+		handler, mock, err := bootstrap()
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+
+		rows := sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active", "utm_source", "utm_campaign"}).
+			AddRow(5, c.destination, "qwerty", 1, true, "newsletter", "spring")
+		expectLinkByHash(mock, rows)
+
+		wr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/qwerty", nil)
+		req.SetPathValue("hash", "qwerty")
+		handler.GoTo()(wr, req)
+
+		if location := wr.Header().Get("Location"); location != c.expected {
+			t.Errorf("Got location %s expected %s", location, c.expected)
+		}
+	}
+}
+
+func TestRevertRestoresOldValues(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	expectLinkWithRelations(mock, sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active", "redirect_code"}).
+		AddRow(5, "https://wrong.com", "qwerty", 1, true, 307), sqlmock.NewRows([]string{"id"}), sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id", "link_id", "version", "old", "new"}).
+		AddRow(9, 5, 2,
+			`{"url":"https://right.com","hash":"qwerty","active":true,"redirect_code":307}`,
+			`{"url":"https://wrong.com","hash":"qwerty","active":true,"redirect_code":307}`))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "routing_rules"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "variants"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE "links" SET "updated_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "link_tags"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FOR UPDATE").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery("MAX").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(2))
+	mock.ExpectQuery("INSERT").
+		WithArgs(sqlmock.AnyArg(), 5, 3, 1, "a@a.com", "revert of revision 2", []byte(`["url"]`), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+	mock.ExpectCommit()
+
+	wr := httptest.NewRecorder()
+	req := authedRequest(http.MethodPost, "/link/5/revert/2")
+	req.SetPathValue("id", "5")
+	req.SetPathValue("rev", "2")
+
+	handler.Revert()(wr, req)
+	if wr.Code != http.StatusOK {
+		t.Fatalf("Got %d expected %d: %s", wr.Code, http.StatusOK, wr.Body.String())
+	}
+	var restored link.Link
+	json.NewDecoder(wr.Body).Decode(&restored)
+	if restored.Url != "https://right.com" {
+		t.Errorf("Got url %s expected https://right.com", restored.Url)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRevertRestoresVariants(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	expectLinkWithRelations(mock, sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active"}).
+		AddRow(5, "https://shop.test", "qwerty", 1, true),
+		sqlmock.NewRows([]string{"id"}),
+		sqlmock.NewRows([]string{"id", "link_id", "name", "url", "weight"}).AddRow(1, 5, "a", "https://wrong.test", 1))
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id", "link_id", "version", "old", "new"}).
+		AddRow(9, 5, 2,
+			`{"url":"https://shop.test","hash":"qwerty","active":true,"variants":[{"name":"a","url":"https://right.test","weight":1}]}`,
+			`{"url":"https://shop.test","hash":"qwerty","active":true,"variants":[{"name":"a","url":"https://wrong.test","weight":1}]}`))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "routing_rules"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "variants"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "variants"`).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 5, "a", "https://right.test", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectExec(`UPDATE "links" SET "updated_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "link_tags"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FOR UPDATE").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery("MAX").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(2))
+	mock.ExpectQuery("INSERT").
+		WithArgs(sqlmock.AnyArg(), 5, 3, 1, "a@a.com", "revert of revision 2", []byte(`["variants"]`), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+	mock.ExpectCommit()
+
+	wr := httptest.NewRecorder()
+	req := authedRequest(http.MethodPost, "/link/5/revert/2")
+	req.SetPathValue("id", "5")
+	req.SetPathValue("rev", "2")
+
+	handler.Revert()(wr, req)
+	if wr.Code != http.StatusOK {
+		t.Fatalf("Got %d expected %d: %s", wr.Code, http.StatusOK, wr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdateRecordsVariantsInOneTransaction(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	expectLinkWithRelations(mock, sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active"}).
+		AddRow(5, "https://shop.test", "qwerty", 1, true),
+		sqlmock.NewRows([]string{"id"}),
+		sqlmock.NewRows([]string{"id", "link_id", "name", "url", "weight"}).AddRow(1, 5, "a", "https://right.test", 1))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE "links"`).WillReturnRows(sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active"}).
+		AddRow(5, "https://shop.test", "qwerty", 1, true))
+	mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "variants"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "variants"`).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 5, "a", "https://wrong.test", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FOR UPDATE").WillReturnError(errors.New("lock failed"))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	body := `{"url":"https://shop.test","variants":[{"name":"a","url":"https://wrong.test","weight":1}]}`
+	wr := httptest.NewRecorder()
+	req := authedRequestWithBody(http.MethodPatch, "/link/5", strings.NewReader(body))
+	req.SetPathValue("id", "5")
+
+	// The revision fails, so the new variants are rolled back with it.
+	handler.Update()(wr, req)
+	if wr.Code != http.StatusBadRequest {
+		t.Errorf("Got %d expected %d: %s", wr.Code, http.StatusBadRequest, wr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRevertMissingRevision(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	expectLinkWithRelations(mock, sqlmock.NewRows([]string{"id", "url", "hash", "user_id"}).
+		AddRow(5, "https://a.com", "qwerty", 1), sqlmock.NewRows([]string{"id"}), sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	wr := httptest.NewRecorder()
+	req := authedRequest(http.MethodPost, "/link/5/revert/7")
+	req.SetPathValue("id", "5")
+	req.SetPathValue("rev", "7")
+
+	handler.Revert()(wr, req)
+	if wr.Code != http.StatusNotFound {
+		t.Errorf("Got %d expected %d", wr.Code, http.StatusNotFound)
+	}
+}
+
+func TestRestoreDeletedLink(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	mock.ExpectQuery("deleted_at is not null").WillReturnRows(sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "deleted_at"}).
+		AddRow(5, "https://a.com", "qwerty", 1, time.Now().Add(-time.Hour)))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	wr := httptest.NewRecorder()
+	req := authedRequest(http.MethodPost, "/link/5/restore")
+	req.SetPathValue("id", "5")
+
+	handler.Restore()(wr, req)
+	if wr.Code != http.StatusOK {
+		t.Fatalf("Got %d expected %d: %s", wr.Code, http.StatusOK, wr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPurgeLiveLink(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// Only links in the trash can be purged.
+	mock.ExpectQuery("deleted_at is not null").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	wr := httptest.NewRecorder()
+	req := authedRequest(http.MethodDelete, "/link/5/purge")
+	req.SetPathValue("id", "5")
+
+	handler.Purge()(wr, req)
+	if wr.Code != http.StatusNotFound {
+		t.Errorf("Got %d expected %d", wr.Code, http.StatusNotFound)
+	}
+}
+
+func TestGetAllLinksCursor(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// One row more than the page size means there is a next page.
+	mock.ExpectQuery(`ORDER BY \(select coalesce\(sum\(stats.clicks\), 0\) .*\) desc,links.id desc LIMIT \$\d`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "total_clicks"}).
+			AddRow(7, "https://a.com", "a", 1, 30).
+			AddRow(4, "https://b.com", "b", 1, 20).
+			AddRow(9, "https://c.com", "c", 1, 20))
+	mock.ExpectQuery("link_tags").WillReturnRows(sqlmock.NewRows([]string{"link_id", "tag_id"}))
+
+	wr := httptest.NewRecorder()
+	req := authedRequest(http.MethodGet, "/link?sort=clicks&order=desc")
+	handler.GetAllLinks()(wr, req)
+	if wr.Code != http.StatusOK {
+		t.Fatalf("Got %d expected %d: %s", wr.Code, http.StatusOK, wr.Body.String())
+	}
+	var page link.GetAllLinksResponse
+	json.NewDecoder(wr.Body).Decode(&page)
+	if len(page.Links) != 2 || page.Next == "" || page.Count != nil {
+		t.Fatalf("Got %d links, next %q, count %v", len(page.Links), page.Next, page.Count)
+	}
+	next := page.Next
+
+	mock.ExpectQuery(`\(\(select coalesce\(sum\(stats.clicks\), 0\) .*\), links.id\) < \(\$1, \$2\)`).
+		WithArgs(20, 4, 1, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "total_clicks"}).
+			AddRow(9, "https://c.com", "c", 1, 20))
+	mock.ExpectQuery("link_tags").WillReturnRows(sqlmock.NewRows([]string{"link_id", "tag_id"}))
+
+	wr = httptest.NewRecorder()
+	req = authedRequest(http.MethodGet, "/link?sort=clicks&order=desc&cursor="+page.Next)
+	handler.GetAllLinks()(wr, req)
+	page = link.GetAllLinksResponse{}
+	json.NewDecoder(wr.Body).Decode(&page)
+	if len(page.Links) != 1 || page.Next != "" {
+		t.Errorf("Got %d links, next %q", len(page.Links), page.Next)
+	}
+
+	// A cursor is bound to the order it was made for.
+	wr = httptest.NewRecorder()
+	req = authedRequest(http.MethodGet, "/link?sort=url&cursor="+next)
+	handler.GetAllLinks()(wr, req)
+	if wr.Code != http.StatusBadRequest {
+		t.Errorf("Got %d expected %d", wr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestGetAllLinksRejectsOffset(t *testing.T) {
+	handler, _, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	wr := httptest.NewRecorder()
+	req := authedRequest(http.MethodGet, "/link?offset=20")
+	handler.GetAllLinks()(wr, req)
+	if wr.Code != http.StatusBadRequest {
+		t.Fatalf("Got %d expected %d", wr.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(wr.Body.String(), "cursor") {
+		t.Errorf("Got %q, expected a hint at cursor", wr.Body.String())
+	}
+}
+
+func TestGetAllBadCursorValue(t *testing.T) {
+	handler, _, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	links, err := handler.LinkRepository.GetAll(link.LinkFilter{
+		UserID: 1,
+		Sort:   link.SortClicks,
+		After:  &link.LinkCursor{Sort: link.SortClicks, Value: "many", ID: 4},
+	})
+	if err == nil || err.Error() != link.ErrInvalidCursor {
+		t.Errorf("Got %v expected %q", err, link.ErrInvalidCursor)
+	}
+	if links != nil {
+		t.Errorf("Got %d links expected none", len(links))
+	}
+}
+
+func TestGoToOnCustomDomain(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	handler.DomainRepository = domain.NewDomainRepository(handler.LinkRepository.DataBase)
+
+	mock.ExpectQuery(`"domains".*host = \$1`).WithArgs("go.brand.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "host", "verified_at"}).AddRow(3, "go.brand.com", time.Now()))
+	expectLinkByHash(mock, sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active", "domain_id"}).
+		AddRow(5, "https://brand.com/sale", "sale", 1, true, 3))
+
+	wr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "http://go.brand.com:443/sale", nil)
+	req.SetPathValue("hash", "sale")
+
+	handler.GoTo()(wr, req)
+	if wr.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("Got %d expected %d", wr.Code, http.StatusTemporaryRedirect)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGoToServedFromCache(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	handler.LinkRepository.Cache = link.NewMemoryLinkCache(10, time.Minute, time.Minute)
+
+	expectLinkByHash(mock, sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active"}).
+		AddRow(5, "https://example.com", "cached", 1, true))
+
+	goTo := func(hash string) int {
+		wr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/"+hash, nil)
+		req.SetPathValue("hash", hash)
+		handler.GoTo()(wr, req)
+		return wr.Code
+	}
+
+	for range 2 {
+		if code := goTo("cached"); code != http.StatusTemporaryRedirect {
+			t.Fatalf("Got %d expected %d", code, http.StatusTemporaryRedirect)
+		}
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "links" SET "deleted_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := handler.LinkRepository.Delete(5, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery("SELECT").WillReturnError(gorm.ErrRecordNotFound)
+	for range 2 {
+		if code := goTo("cached"); code != http.StatusNotFound {
+			t.Fatalf("Got %d expected %d", code, http.StatusNotFound)
+		}
+	}
+
+	stats := handler.LinkRepository.Cache.Stats()
+	if stats.Hits != 2 || stats.NegativeHits != 1 {
+		t.Errorf("Got %+v", stats)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGoToOpensApp(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	for range 2 {
+		expectLinkByHash(mock, sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active", "ios_deep_link", "ios_store_url"}).
+			AddRow(5, "https://shop.test/p/42", "app", 1, true, "myapp://product/42", "https://apps.apple.com/app/id1"))
+	}
+
+	wr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/app", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)")
+	req.SetPathValue("hash", "app")
+
+	handler.GoTo()(wr, req)
+	if wr.Code != http.StatusOK {
+		t.Fatalf("Got %d expected %d", wr.Code, http.StatusOK)
+	}
+	body := wr.Body.String()
+	if !strings.Contains(body, `href="myapp://product/42"`) || !strings.Contains(body, `"https://apps.apple.com/app/id1"`) {
+		t.Errorf("Got page %s", body)
+	}
+
+	// Visitors on other platforms are redirected as usual.
+	wr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/app", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64)")
+	req.SetPathValue("hash", "app")
+
+	handler.GoTo()(wr, req)
+	if wr.Code != http.StatusTemporaryRedirect {
+		t.Errorf("Got %d expected %d", wr.Code, http.StatusTemporaryRedirect)
+	}
+}
+
+func TestCreateLinkRejectsScriptDeepLink(t *testing.T) {
+	handler, _, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	body := `{"url":"https://shop.test","ios_deep_link":"javascript:alert(1)"}`
+	wr := httptest.NewRecorder()
+	req := authedRequestWithBody(http.MethodPost, "/link", strings.NewReader(body))
+
+	handler.Create()(wr, req)
+	if wr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Got %d expected %d: %s", wr.Code, http.StatusUnprocessableEntity, wr.Body.String())
+	}
+}
+
+func TestCreateLinkAliasRace(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// The alias is free when checked but taken by the time of the insert.
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "links" WHERE hash = \$1 AND domain_id is null`).WithArgs("sale").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "links"`).
+		WillReturnError(&pgconn.PgError{Code: "23505", Message: "duplicate key value violates unique constraint"})
+	mock.ExpectRollback()
+
+	body := `{"url":"https://shop.test","alias":"sale"}`
+	wr := httptest.NewRecorder()
+	req := authedRequestWithBody(http.MethodPost, "/link", strings.NewReader(body))
+
+	handler.Create()(wr, req)
+	if wr.Code != http.StatusConflict {
+		t.Fatalf("Got %d expected %d: %s", wr.Code, http.StatusConflict, wr.Body.String())
+	}
+	if got := strings.TrimSpace(wr.Body.String()); got != link.ErrAliasTaken {
+		t.Errorf("Got %q expected %q", got, link.ErrAliasTaken)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGoToPublishesVisitContext(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	events := handler.EventBus.Subscribe()
+
+	expectLinkByHash(mock, sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active"}).
+		AddRow(5, "https://shop.test", "visit", 1, true))
+
+	wr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/visit", nil)
+	req.RemoteAddr = "203.0.113.77:5000"
+	req.Header.Set("Referer", "https://news.test/story?id=1")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64)")
+	req.Header.Set("Accept-Language", "de-CH, en;q=0.8")
+	req.SetPathValue("hash", "visit")
+
+	handler.GoTo()(wr, req)
+
+	select {
+	case msg := <-events:
+		visit := msg.Data.(event.Visit)
+		if msg.Type != event.LinkVisited || visit.LinkID != 5 || visit.Referrer != "https://news.test/story?id=1" ||
+			visit.IP.String() != "203.0.113.77" || visit.Language != "de" || visit.Time.IsZero() {
+			t.Errorf("Got %s %+v", msg.Type, visit)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No visit published")
+	}
+}
+
+func TestCloneKeepsDisabledLink(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	expectLinkWithRelations(mock, sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active"}).
+		AddRow(5, "https://shop.test/sale", "qwerty", 1, false), sqlmock.NewRows([]string{"id"}), sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("count").WithArgs("copy").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT").WithArgs(insertArgs(map[string]driver.Value{"hash": "copy", "active": false})...).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+	mock.ExpectCommit()
+
+	wr := httptest.NewRecorder()
+	req := authedRequestWithBody(http.MethodPost, "/link/5/clone", strings.NewReader(`{"alias":"copy"}`))
+	req.SetPathValue("id", "5")
+	handler.Clone()(wr, req)
+
+	if wr.Code != http.StatusCreated {
+		t.Fatalf("Got %d expected %d: %s", wr.Code, http.StatusCreated, wr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCloneChecksEveryDestination(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// The variant points somewhere the policy refuses today.
+	expectLinkWithRelations(mock, sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active"}).
+		AddRow(5, "https://shop.test/sale", "qwerty", 1, true),
+		sqlmock.NewRows([]string{"id", "link_id", "url"}).AddRow(1, 5, "https://shop.test/mobile"),
+		sqlmock.NewRows([]string{"id", "link_id", "name", "url", "weight"}).AddRow(2, 5, "b", "ftp://shop.test/b", 1))
+
+	wr := httptest.NewRecorder()
+	req := authedRequest(http.MethodPost, "/link/5/clone")
+	req.SetPathValue("id", "5")
+	handler.Clone()(wr, req)
+
+	if wr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Got %d expected %d: %s", wr.Code, http.StatusUnprocessableEntity, wr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetAllLinksFilters(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// Every filter is scoped to the caller and q is matched literally.
+	mock.ExpectQuery(`WHERE links.user_id = \$1 AND \(exists \(select 1 from link_tags join tags on tags.id = link_tags.tag_id where link_tags.link_id = links.id and tags.name = \$2\)\) AND links.folder_id = \$3 AND \(\(links.url ilike \$4 or links.title ilike \$5\)\)`).
+		WithArgs(1, "promo", 3, `%50\%\_off%`, `%50\%\_off%`, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "hash", "user_id"}))
+
+	wr := httptest.NewRecorder()
+	req := authedRequest(http.MethodGet, "/link?tag=promo&folder=3&q=50%25_off")
+	handler.GetAllLinks()(wr, req)
+
+	if wr.Code != http.StatusOK {
+		t.Errorf("Got %d expected %d: %s", wr.Code, http.StatusOK, wr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFolderDeleteMovesLinksOut(t *testing.T) {
+	linkSchema, err := schema.Parse(&link.Link{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	constraint := linkSchema.Relationships.Relations["Folder"].ParseConstraint()
+	if constraint == nil || constraint.OnDelete != "SET NULL" {
+		t.Errorf("Got constraint %+v expected ON DELETE SET NULL", constraint)
+	}
+}
+
+func TestUpdateReplacesTags(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	expectLinkWithRelations(mock, sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active"}).
+		AddRow(5, "https://shop.test", "qwerty", 1, true), sqlmock.NewRows([]string{"id"}), sqlmock.NewRows([]string{"id"}))
+	// The caller's tags are found or created first, then swapped in
+	// together with the edit and its revision.
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "tags" .* ON CONFLICT DO NOTHING`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`"tags" WHERE \(user_id = \$1 and name in \(\$2\)\)`).WithArgs(1, "promo").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "user_id"}).AddRow(3, "promo", 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE "links"`).WillReturnRows(sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active"}).
+		AddRow(5, "https://shop.test", "qwerty", 1, true))
+	mock.ExpectExec(`UPDATE "links" SET "updated_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "tags"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectExec(`INSERT INTO "link_tags"`).WithArgs(5, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "link_tags" WHERE "link_tags"."link_id" = \$1 AND "link_tags"."tag_id" <> \$2`).WithArgs(5, 3).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FOR UPDATE").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery("MAX").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(0))
+	mock.ExpectQuery(`INSERT INTO "link_revisions"`).
+		WithArgs(sqlmock.AnyArg(), 5, 1, 1, "a@a.com", "", []byte(`["tags"]`), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	wr := httptest.NewRecorder()
+	req := authedRequestWithBody(http.MethodPatch, "/link/5", strings.NewReader(`{"url":"https://shop.test","tags":["promo"]}`))
+	req.SetPathValue("id", "5")
+	handler.Update()(wr, req)
+
+	if wr.Code != http.StatusCreated {
+		t.Errorf("Got %d expected %d: %s", wr.Code, http.StatusCreated, wr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdateClearsFields(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	expectLinkWithRelations(mock, sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active", "title", "folder_id", "utm_source"}).
+		AddRow(5, "https://shop.test", "qwerty", 1, true, "Sale", 3, "newsletter"), sqlmock.NewRows([]string{"id"}), sqlmock.NewRows([]string{"id"}))
+	args := []driver.Value{sqlmock.AnyArg(), "https://shop.test", "qwerty", "", "", nil}
+	for range 12 {
+		args = append(args, sqlmock.AnyArg())
+	}
+	args = append(args, 1, 5)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE "links" SET "updated_at"=\$1,"url"=\$2,"hash"=\$3,"title"=\$4,"description"=\$5,"folder_id"=\$6,.*"utm_source"=`).
+		WithArgs(args...).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active"}).
+			AddRow(5, "https://shop.test", "qwerty", 1, true))
+	mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FOR UPDATE").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery("MAX").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(0))
+	mock.ExpectQuery(`INSERT INTO "link_revisions"`).
+		WithArgs(sqlmock.AnyArg(), 5, 1, 1, "a@a.com", "", []byte(`["title","folder_id","utm_source"]`), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	// Leaving out the hash keeps it; leaving out anything else clears it.
+	wr := httptest.NewRecorder()
+	req := authedRequestWithBody(http.MethodPatch, "/link/5", strings.NewReader(`{"url":"https://shop.test"}`))
+	req.SetPathValue("id", "5")
+	handler.Update()(wr, req)
+
+	if wr.Code != http.StatusCreated {
+		t.Errorf("Got %d expected %d: %s", wr.Code, http.StatusCreated, wr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
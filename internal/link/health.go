@@ -0,0 +1,274 @@
+package link
+
+import (
+	"context"
+	"demo/go-server/pkg/response"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	HealthBroken    = "broken"
+	HealthHealthy   = "healthy"
+	HealthUnchecked = "unchecked"
+)
+
+// HealthChecker probes link destinations with HEAD, falling back to GET
+// for servers that don't answer HEAD.
+type HealthChecker struct {
+	Client *http.Client
+}
+
+func NewHealthChecker(timeout time.Duration, maxRedirects int) *HealthChecker {
+	return &HealthChecker{
+		Client: &http.Client{
+			Timeout: timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) > maxRedirects {
+					return errors.New(ErrTooManyRedirects)
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// Check requests target once and reports the outcome. 4xx and 5xx
+// answers, DNS failures and other transport errors count as broken.
+func (c *HealthChecker) Check(ctx context.Context, linkID uint, target string) *LinkHealth {
+	health := &LinkHealth{LinkID: linkID}
+	start := time.Now()
+
+	res, err := c.do(ctx, http.MethodHead, target)
+	if err == nil && (res.StatusCode == http.StatusMethodNotAllowed || res.StatusCode == http.StatusNotImplemented) {
+		res, err = c.do(ctx, http.MethodGet, target)
+	}
+
+	health.LatencyMs = time.Since(start).Milliseconds()
+	health.CheckedAt = time.Now()
+	if err != nil {
+		health.Broken = true
+		health.Error = describeCheckError(err)
+		return health
+	}
+
+	health.StatusCode = res.StatusCode
+	health.Broken = res.StatusCode >= 400
+	return health
+}
+
+func (c *HealthChecker) do(ctx context.Context, method, target string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	// Only the status matters; don't download the page.
+	io.CopyN(io.Discard, res.Body, 4096)
+	res.Body.Close()
+	return res, nil
+}
+
+func describeCheckError(err error) string {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return "dns: " + dnsErr.Err
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if urlErr.Timeout() {
+			return "timeout"
+		}
+		return urlErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// hostLimiter spaces out requests to the same host by at least gap.
+type hostLimiter struct {
+	mu   sync.Mutex
+	gap  time.Duration
+	next map[string]time.Time
+}
+
+func newHostLimiter(gap time.Duration) *hostLimiter {
+	return &hostLimiter{
+		gap:  gap,
+		next: make(map[string]time.Time),
+	}
+}
+
+// Wait blocks until a request to the host of target may be sent.
+func (l *hostLimiter) Wait(ctx context.Context, target string) error {
+	host := target
+	if parsed, err := url.Parse(target); err == nil {
+		host = strings.ToLower(parsed.Hostname())
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[host] = at.Add(l.gap)
+	l.mu.Unlock()
+
+	if delay := at.Sub(now); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// forget drops hosts whose slot has passed so the map doesn't grow with
+// every host ever checked.
+func (l *hostLimiter) forget(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for host, at := range l.next {
+		if at.Before(now) {
+			delete(l.next, host)
+		}
+	}
+}
+
+func (handler *LinkHandler) Health() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, ok := handler.currentUserID(w, req)
+		if !ok {
+			return
+		}
+
+		link, ok := handler.ownedLink(w, req, userID)
+		if !ok {
+			return
+		}
+
+		health, err := handler.LinkRepository.GetHealth(link.ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, ErrHealthUnchecked, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response.WriteResponse(w, health, 200)
+	}
+}
+
+type HealthServiceDeps struct {
+	LinkRepository *LinkRepository
+	HealthChecker  *HealthChecker
+	Interval       time.Duration
+	Concurrency    int
+	HostInterval   time.Duration
+}
+
+// HealthService checks the url of every active link once per Interval,
+// with at most Concurrency requests in flight and at least HostInterval
+// between two requests to the same host.
+type HealthService struct {
+	LinkRepository *LinkRepository
+	HealthChecker  *HealthChecker
+	Interval       time.Duration
+	Concurrency    int
+	HostInterval   time.Duration
+}
+
+func NewHealthService(deps *HealthServiceDeps) *HealthService {
+	return &HealthService{
+		LinkRepository: deps.LinkRepository,
+		HealthChecker:  deps.HealthChecker,
+		Interval:       deps.Interval,
+		Concurrency:    max(deps.Concurrency, 1),
+		HostInterval:   deps.HostInterval,
+	}
+}
+
+// healthBatchSize is how many links a round loads at a time.
+const healthBatchSize = 500
+
+// CheckLinks runs a round every Interval. A zero interval turns the
+// checks off.
+func (s *HealthService) CheckLinks() {
+	if s.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		s.CheckRound(context.Background())
+		<-ticker.C
+	}
+}
+
+// CheckRound checks every active link once and returns how many were
+// checked. Links are loaded in batches; each link waits for its host's
+// turn and then for a free slot, so a slow host doesn't hold up others.
+func (s *HealthService) CheckRound(ctx context.Context) int {
+	limiter := newHostLimiter(s.HostInterval)
+	slots := make(chan struct{}, s.Concurrency)
+	checked := 0
+
+	var lastID uint
+	for ctx.Err() == nil {
+		links := s.LinkRepository.GetActiveAfter(lastID, healthBatchSize)
+		if len(links) == 0 {
+			break
+		}
+		lastID = links[len(links)-1].ID
+
+		var wg sync.WaitGroup
+		for _, link := range links {
+			wg.Add(1)
+			go func(id uint, target string) {
+				defer wg.Done()
+				if err := limiter.Wait(ctx, target); err != nil {
+					return
+				}
+				slots <- struct{}{}
+				defer func() { <-slots }()
+				s.checkLink(ctx, id, target)
+			}(link.ID, link.Url)
+		}
+		wg.Wait()
+
+		checked += len(links)
+		limiter.forget(time.Now())
+	}
+
+	return checked
+}
+
+func (s *HealthService) checkLink(ctx context.Context, id uint, target string) {
+	health := s.HealthChecker.Check(ctx, id, target)
+	if err := s.LinkRepository.SaveHealth(health); err != nil {
+		log.Println("Health of link", id, "not saved:", err)
+		return
+	}
+	if health.Broken {
+		log.Println("Link", id, "is broken:", target, health.StatusCode, health.Error)
+	}
+}
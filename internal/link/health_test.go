@@ -0,0 +1,90 @@
+package link_test
+
+import (
+	"context"
+	"demo/go-server/internal/link"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestHealthCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/ok":
+		case "/gone":
+			w.WriteHeader(http.StatusNotFound)
+		case "/no-head":
+			if req.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		}
+	}))
+	defer server.Close()
+
+	checker := link.NewHealthChecker(time.Second, 3)
+	for target, broken := range map[string]bool{
+		server.URL + "/ok":              false,
+		server.URL + "/gone":            true,
+		server.URL + "/no-head":         false,
+		"http://does-not-exist.invalid": true,
+	} {
+		health := checker.Check(context.Background(), 1, target)
+		if health.Broken != broken {
+			t.Errorf("%s: got broken %v (status %d, %q)", target, health.Broken, health.StatusCode, health.Error)
+		}
+	}
+}
+
+func TestHealthRound(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		if req.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+		}
+	}))
+	defer server.Close()
+
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectQuery("SELECT").WithArgs(0, 500).WillReturnRows(sqlmock.NewRows([]string{"id", "url"}).
+		AddRow(1, server.URL+"/ok").
+		AddRow(2, server.URL+"/gone"))
+	mock.ExpectQuery("SELECT").WithArgs(2, 500).WillReturnRows(sqlmock.NewRows([]string{"id", "url"}))
+	for range 2 {
+		mock.ExpectBegin()
+		mock.ExpectExec("ON CONFLICT").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	service := link.NewHealthService(&link.HealthServiceDeps{
+		LinkRepository: handler.LinkRepository,
+		HealthChecker:  link.NewHealthChecker(time.Second, 3),
+		Concurrency:    2,
+		HostInterval:   50 * time.Millisecond,
+	})
+
+	start := time.Now()
+	if checked := service.CheckRound(context.Background()); checked != 2 {
+		t.Errorf("Got %d checked expected 2", checked)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("Got %d requests expected 2", requests.Load())
+	}
+	// Both links share a host, so the second waits for its turn.
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Round took %s, host interval not respected", elapsed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,153 @@
+package link
+
+import (
+	"demo/go-server/internal/tag"
+	"demo/go-server/pkg/request"
+	"demo/go-server/pkg/response"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+const (
+	revertReason = "revert of revision %d"
+	passwordMask = "********"
+)
+
+func (handler *LinkHandler) History() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, ok := handler.currentUserID(w, req)
+		if !ok {
+			return
+		}
+
+		link, ok := handler.ownedLink(w, req, userID)
+		if !ok {
+			return
+		}
+
+		revisions := handler.LinkRepository.GetRevisions(link.ID)
+		for i := range revisions {
+			revisions[i].Old.Password = maskPassword(revisions[i].Old.Password)
+			revisions[i].New.Password = maskPassword(revisions[i].New.Password)
+		}
+
+		response.WriteResponse(w, revisions, 200)
+	}
+}
+
+// Revert undoes revision {rev}: the link gets back the values it had
+// before that change, rules, variants and tags included. The revert is
+// recorded as a revision of its own.
+func (handler *LinkHandler) Revert() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		author, ok := handler.currentUser(w, req)
+		if !ok {
+			return
+		}
+
+		version, err := strconv.ParseUint(req.PathValue("rev"), 10, 32)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		reason := fmt.Sprintf(revertReason, version)
+		if req.ContentLength > 0 {
+			body, err := request.HandleBody[LinkRevertRequest](&w, req)
+			if err != nil {
+				return
+			}
+			if body.Reason != "" {
+				reason = body.Reason
+			}
+		}
+
+		link, ok := handler.ownedLinkFrom(w, req, author.ID, handler.LinkRepository.GetWithRelations)
+		if !ok {
+			return
+		}
+
+		revision, err := handler.LinkRepository.GetRevision(link.ID, uint(version))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, ErrRevisionNotFound, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		target := revision.Old
+		if status, err := handler.checkUrl(req, target.Url); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		if target.Hash != link.Hash && handler.LinkRepository.HashExists(link.DomainID, target.Hash) {
+			http.Error(w, ErrAliasTaken, http.StatusConflict)
+			return
+		}
+		if target.FolderID != nil {
+			if status, err := handler.checkFolder(*target.FolderID, author.ID); err != nil {
+				http.Error(w, err.Error(), status)
+				return
+			}
+		}
+
+		rules, status, err := handler.buildRules(req, target.Rules)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		variants, status, err := handler.buildVariants(req, target.Variants)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		var tags []tag.Tag
+		if len(target.Tags) > 0 {
+			if tags, err = handler.TagRepository.GetOrCreate(author.ID, target.Tags); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		before := *link
+		target.applyTo(link)
+		err = handler.LinkRepository.Transaction(func(repo *LinkRepository) error {
+			if err := repo.SaveSnapshot(link); err != nil {
+				return err
+			}
+			if err := repo.ReplaceRules(link, rules); err != nil {
+				return err
+			}
+			if err := repo.ReplaceVariants(link, variants); err != nil {
+				return err
+			}
+			if err := repo.ReplaceTags(link, tags); err != nil {
+				return err
+			}
+			link.Rules, link.Variants, link.Tags = rules, variants, tags
+			return recordRevision(repo, author, &before, link, reason)
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		handler.present(req, link)
+		response.WriteResponse(w, link, 200)
+	}
+}
+
+// maskPassword hides the password hash of a snapshot from the history,
+// which only needs to show that one is set.
+func maskPassword(password string) string {
+	if password == "" {
+		return ""
+	}
+	return passwordMask
+}
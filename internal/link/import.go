@@ -0,0 +1,635 @@
+package link
+
+import (
+	"bufio"
+	"demo/go-server/internal/domain"
+	"demo/go-server/internal/user"
+	"demo/go-server/pkg/event"
+	"demo/go-server/pkg/request"
+	"demo/go-server/pkg/response"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	OnConflictSkip      = "skip"
+	OnConflictRename    = "rename"
+	OnConflictOverwrite = "overwrite"
+)
+
+// importBatchSize is how many new links Import stores per transaction.
+const importBatchSize = 500
+
+// importRow is one decoded row of an import. Row is the line in csv and
+// ndjson input and the position in a json array. Err holds a problem
+// found while decoding it.
+type importRow struct {
+	Row  int
+	Link LinkExport
+	Err  error
+}
+
+// Import creates links from an export-shaped file, row by row: a bad row
+// is reported and skipped instead of failing the whole import. Hashes
+// already in use are skipped, renamed or overwritten as on_conflict says,
+// and dry_run=true checks everything without writing.
+func (handler *LinkHandler) Import() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		author, ok := handler.currentUser(w, req)
+		if !ok {
+			return
+		}
+
+		query := req.URL.Query()
+		onConflict := query.Get("on_conflict")
+		switch onConflict {
+		case "":
+			onConflict = OnConflictSkip
+		case OnConflictSkip, OnConflictRename, OnConflictOverwrite:
+		default:
+			http.Error(w, ErrOnConflict, http.StatusBadRequest)
+			return
+		}
+
+		maxRows := handler.Config.Link.ImportMaxRows
+		rows, err := decodeImportRows(req, query.Get("format"), maxRows+1)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(rows) == 0 {
+			http.Error(w, ErrBulkEmpty, http.StatusBadRequest)
+			return
+		}
+		if len(rows) > maxRows {
+			http.Error(w, fmt.Sprintf(ErrImportTooLarge, maxRows), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		run := &linkImport{
+			handler:    handler,
+			req:        req.WithContext(handler.UrlPolicy.WithRules(req.Context())),
+			author:     author,
+			onConflict: onConflict,
+			dryRun:     query.Get("dry_run") == "true",
+			taken:      make(map[string]struct{}, len(rows)),
+			domains:    map[string]*uint{},
+			folders:    map[string]*uint{},
+		}
+		run.data.DryRun = run.dryRun
+		run.data.Rows = len(rows)
+		run.data.Renamed = []LinkImportRename{}
+		run.data.Errors = []LinkImportError{}
+		for _, row := range rows {
+			run.add(row)
+		}
+		run.flush()
+
+		response.WriteResponse(w, run.data, 200)
+	}
+}
+
+// linkImport carries the state of one Import call.
+type linkImport struct {
+	handler    *LinkHandler
+	req        *http.Request
+	author     *user.User
+	onConflict string
+	dryRun     bool
+	taken      map[string]struct{}
+	domains    map[string]*uint
+	folders    map[string]*uint
+	pending    []pendingLink
+	data       LinkImportResponse
+}
+
+type pendingLink struct {
+	row    importRow
+	link   *Link
+	rename *LinkImportRename
+}
+
+func (run *linkImport) add(row importRow) {
+	if row.Err != nil {
+		run.fail(row, http.StatusUnprocessableEntity, row.Err)
+		return
+	}
+
+	body, status, err := run.request(row.Link)
+	if err != nil {
+		run.fail(row, status, err)
+		return
+	}
+	if err := request.IsValid(body); err != nil {
+		run.fail(row, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	var rename *LinkImportRename
+	if body.Alias != "" {
+		if err := ValidateAlias(body.Alias); err != nil {
+			run.fail(row, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		_, inFile := run.taken[hashKey(body.DomainID, body.Alias)]
+		if inFile || run.handler.LinkRepository.HashExists(body.DomainID, body.Alias) {
+			switch run.onConflict {
+			case OnConflictSkip:
+				run.data.Skipped++
+				return
+			case OnConflictOverwrite:
+				if inFile {
+					run.fail(row, http.StatusConflict, errors.New(ErrAliasTaken))
+					return
+				}
+				run.overwrite(row, &body)
+				return
+			case OnConflictRename:
+				renamed, ok := run.rename(body.DomainID, body.Alias)
+				if !ok {
+					run.fail(row, http.StatusConflict, errors.New(ErrAliasTaken))
+					return
+				}
+				rename = &LinkImportRename{Row: row.Row, From: body.Alias, To: renamed}
+				body.Alias = renamed
+			}
+		}
+	}
+
+	link, status, err := run.prepare(&body, row.Link)
+	if err != nil {
+		run.fail(row, status, err)
+		return
+	}
+	if body.Alias != "" {
+		link.Hash = body.Alias
+	} else if !run.dryRun {
+		if status, err := run.handler.assignHash(link, "", run.taken); err != nil {
+			run.fail(row, status, err)
+			return
+		}
+	}
+	if link.Hash != "" {
+		run.taken[hashKey(link.DomainID, link.Hash)] = struct{}{}
+	}
+
+	if run.dryRun {
+		run.data.Created++
+		if rename != nil {
+			run.data.Renamed = append(run.data.Renamed, *rename)
+		}
+		return
+	}
+	run.pending = append(run.pending, pendingLink{row: row, link: link, rename: rename})
+	if len(run.pending) >= importBatchSize {
+		run.flush()
+	}
+}
+
+// request turns a row into a create request, resolving the domain host
+// and folder name to ids.
+func (run *linkImport) request(row LinkExport) (LinkCreateRequest, int, error) {
+	body := LinkCreateRequest{
+		Url:             row.Url,
+		Alias:           row.Hash,
+		Title:           row.Title,
+		Description:     row.Description,
+		Tags:            row.Tags,
+		Rules:           row.Rules,
+		Variants:        row.Variants,
+		StartsAt:        row.StartsAt,
+		RedirectCode:    row.RedirectCode,
+		RedirectMode:    row.RedirectMode,
+		ForwardQuery:    row.ForwardQuery,
+		ExpiresAt:       row.ExpiresAt,
+		MaxClicks:       row.MaxClicks,
+		Password:        row.Password,
+		UtmSource:       row.UtmSource,
+		UtmMedium:       row.UtmMedium,
+		UtmCampaign:     row.UtmCampaign,
+		UtmTerm:         row.UtmTerm,
+		UtmContent:      row.UtmContent,
+		AppLinksRequest: row.AppLinksRequest,
+	}
+
+	if row.PasswordHash != "" {
+		if _, err := bcrypt.Cost([]byte(row.PasswordHash)); err != nil {
+			return body, http.StatusUnprocessableEntity, errors.New(ErrImportHash)
+		}
+	} else if row.Protected && row.Password == "" {
+		return body, http.StatusUnprocessableEntity, errors.New(ErrImportProtected)
+	}
+
+	if row.Domain != "" {
+		domainID, err := run.domainID(row.Domain)
+		if err != nil {
+			return body, http.StatusUnprocessableEntity, err
+		}
+		body.DomainID = domainID
+	}
+
+	// A dry run creates no folders.
+	if row.Folder != "" && !run.dryRun {
+		folderID, err := run.folderID(row.Folder)
+		if err != nil {
+			return body, http.StatusInternalServerError, err
+		}
+		body.FolderID = folderID
+	}
+
+	return body, 0, nil
+}
+
+// prepare builds the link of a row. A dry run skips the steps that write
+// (tags) or only cost time (password hashing). A password_hash from an
+// export is kept as it is, unless the row also sets a new password.
+func (run *linkImport) prepare(body *LinkCreateRequest, row LinkExport) (*Link, int, error) {
+	if run.dryRun {
+		body.Tags = nil
+		body.Password = ""
+	}
+
+	link, status, err := run.handler.prepareLink(run.req, body, run.author.ID)
+	if err != nil {
+		return nil, status, err
+	}
+	if row.Active != nil {
+		link.Active = *row.Active
+	}
+	if row.Password == "" && row.PasswordHash != "" {
+		link.Password = row.PasswordHash
+	}
+
+	return link, 0, nil
+}
+
+// overwrite replaces the caller's live link that holds the row's hash.
+// Hashes held by other users or by links in the trash are not touched.
+func (run *linkImport) overwrite(row importRow, body *LinkCreateRequest) {
+	existed, err := run.handler.LinkRepository.GetByHash(body.DomainID, body.Alias)
+	if err != nil || existed.UserID != run.author.ID {
+		run.fail(row, http.StatusConflict, errors.New(ErrAliasTaken))
+		return
+	}
+	// The revision compares tags too, which GetByHash does not load.
+	if !run.dryRun {
+		if existed, err = run.handler.LinkRepository.GetWithRelations(existed.ID); err != nil {
+			run.fail(row, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	link, status, err := run.prepare(body, row.Link)
+	if err != nil {
+		run.fail(row, status, err)
+		return
+	}
+	// Rows without an active column keep the link's current state.
+	if row.Link.Active == nil {
+		link.Active = existed.Active
+	}
+	link.ID = existed.ID
+	link.Hash = existed.Hash
+	link.DomainID = existed.DomainID
+	run.taken[hashKey(link.DomainID, link.Hash)] = struct{}{}
+
+	if !run.dryRun {
+		err = run.handler.LinkRepository.Transaction(func(repo *LinkRepository) error {
+			if err := repo.Overwrite(link); err != nil {
+				return err
+			}
+			if link.Password == "" {
+				link.Password = existed.Password
+			}
+			return recordRevision(repo, run.author, existed, link, "import")
+		})
+		if err != nil {
+			run.fail(row, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	run.data.Updated++
+}
+
+// rename finds a free hash for hash by appending -2, -3 and so on.
+func (run *linkImport) rename(domainID *uint, hash string) (string, bool) {
+	for n := 2; n < 100; n++ {
+		suffix := "-" + strconv.Itoa(n)
+		candidate := hash[:min(len(hash), AliasMaxLength-len(suffix))] + suffix
+		if _, ok := run.taken[hashKey(domainID, candidate)]; ok {
+			continue
+		}
+		if !run.handler.LinkRepository.HashExists(domainID, candidate) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// flush stores the pending links in one transaction.
+func (run *linkImport) flush() {
+	if len(run.pending) == 0 {
+		return
+	}
+	defer func() {
+		run.pending = run.pending[:0]
+	}()
+
+	links := make([]*Link, len(run.pending))
+	for i, pending := range run.pending {
+		links[i] = pending.link
+	}
+	if err := run.handler.LinkRepository.CreateMany(links); err != nil {
+		for _, pending := range run.pending {
+			run.fail(pending.row, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	run.data.Created += len(links)
+	for _, pending := range run.pending {
+		if pending.rename != nil {
+			run.data.Renamed = append(run.data.Renamed, *pending.rename)
+		}
+	}
+	go func() {
+		for _, link := range links {
+			run.handler.EventBus.Publish(event.Event{
+				Type: event.LinkCreated,
+				Data: link.ID,
+			})
+		}
+	}()
+}
+
+func (run *linkImport) fail(row importRow, status int, err error) {
+	run.data.Failed++
+	run.data.Errors = append(run.data.Errors, LinkImportError{
+		Row:    row.Row,
+		Hash:   row.Link.Hash,
+		Status: status,
+		Error:  err.Error(),
+	})
+}
+
+// domainID resolves a host to one of the caller's verified domains.
+func (run *linkImport) domainID(host string) (*uint, error) {
+	host = domain.NormalizeHost(host)
+	domainID, ok := run.domains[host]
+	if !ok {
+		if run.handler.DomainRepository != nil {
+			existed, err := run.handler.DomainRepository.GetVerifiedByHost(host)
+			if err == nil && existed.UserID == run.author.ID {
+				domainID = &existed.ID
+			}
+		}
+		run.domains[host] = domainID
+	}
+
+	if domainID == nil {
+		return nil, errors.New(ErrUnknownDomain)
+	}
+	return domainID, nil
+}
+
+func (run *linkImport) folderID(name string) (*uint, error) {
+	if folderID, ok := run.folders[name]; ok {
+		return folderID, nil
+	}
+
+	existed, err := run.handler.FolderRepository.GetOrCreate(run.author.ID, name)
+	if err != nil {
+		return nil, err
+	}
+	run.folders[name] = &existed.ID
+	return &existed.ID, nil
+}
+
+// decodeImportRows reads at most limit rows in format, which defaults
+// to what the Content-Type says. Files may come as the raw body or as a
+// multipart "file" field.
+func decodeImportRows(req *http.Request, format string, limit int) ([]importRow, error) {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+
+	var body io.Reader = req.Body
+	if mediaType == "multipart/form-data" {
+		file, _, err := req.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		body = file
+	}
+
+	if format == "" {
+		switch mediaType {
+		case "text/csv", "multipart/form-data":
+			format = FormatCsv
+		case "application/x-ndjson":
+			format = FormatNdjson
+		default:
+			format = FormatJson
+		}
+	}
+
+	switch format {
+	case FormatCsv:
+		return parseImportCsv(body, nil, limit)
+	case FormatBitly:
+		return parseImportCsv(body, bitlyColumns, limit)
+	case FormatNdjson:
+		return parseImportNdjson(body, limit)
+	case FormatJson:
+		return parseImportJson(body, limit)
+	default:
+		return nil, errors.New(ErrImportFormat)
+	}
+}
+
+// bitlyColumns maps the columns of a Bitly link export onto ours. Bitly
+// exports full short links, so only their last path segment is kept as
+// the hash.
+var bitlyColumns = map[string]string{
+	"long_url":    "url",
+	"destination": "url",
+	"bitlink":     "hash",
+	"link":        "hash",
+	"created":     "created_at",
+}
+
+// parseImportCsv reads rows with a header of export columns, renamed
+// through columnNames when given.
+func parseImportCsv(r io.Reader, columnNames map[string]string, limit int) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New(ErrBulkCsvHeader)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.NewReplacer(" ", "_", "-", "_").Replace(strings.ToLower(strings.TrimSpace(name)))
+		if renamed, ok := columnNames[name]; ok {
+			name = renamed
+		}
+		if _, ok := columns[name]; !ok {
+			columns[name] = i
+		}
+	}
+	if _, ok := columns["url"]; !ok {
+		return nil, errors.New(ErrBulkCsvHeader)
+	}
+
+	var rows []importRow
+	for len(rows) < limit {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, importCsvRecord(line, record, columns, columnNames != nil))
+	}
+
+	return rows, nil
+}
+
+func importCsvRecord(line int, record []string, columns map[string]int, shortLinks bool) importRow {
+	row := importRow{Row: line}
+	field := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return unescapeCsvFormula(strings.TrimSpace(record[i]))
+	}
+	check := func(name string, err error) {
+		if err != nil && row.Err == nil {
+			row.Err = fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	row.Link = LinkExport{
+		Hash:         field("hash"),
+		Url:          field("url"),
+		Domain:       field("domain"),
+		Title:        field("title"),
+		Description:  field("description"),
+		Folder:       field("folder"),
+		RedirectMode: field("redirect_mode"),
+		ForwardQuery: field("forward_query"),
+		UtmSource:    field("utm_source"),
+		UtmMedium:    field("utm_medium"),
+		UtmCampaign:  field("utm_campaign"),
+		UtmTerm:      field("utm_term"),
+		UtmContent:   field("utm_content"),
+		AppLinksRequest: AppLinksRequest{
+			IosDeepLink:     field("ios_deep_link"),
+			AndroidDeepLink: field("android_deep_link"),
+			IosStoreUrl:     field("ios_store_url"),
+			AndroidStoreUrl: field("android_store_url"),
+		},
+		Password:     field("password"),
+		PasswordHash: field("password_hash"),
+	}
+	if row.Link.Hash == "" {
+		row.Link.Hash = field("alias")
+	}
+	if shortLinks {
+		row.Link.Hash = row.Link.Hash[strings.LastIndex(row.Link.Hash, "/")+1:]
+	}
+
+	for _, name := range strings.FieldsFunc(field("tags"), func(r rune) bool { return r == ',' || r == '|' }) {
+		if name = strings.TrimSpace(name); name != "" {
+			row.Link.Tags = append(row.Link.Tags, name)
+		}
+	}
+	if value := field("active"); value != "" {
+		active, err := strconv.ParseBool(value)
+		check("active", err)
+		row.Link.Active = &active
+	}
+	if value := field("protected"); value != "" {
+		protected, err := strconv.ParseBool(value)
+		check("protected", err)
+		row.Link.Protected = protected
+	}
+	if value := field("redirect_code"); value != "" {
+		code, err := strconv.Atoi(value)
+		check("redirect_code", err)
+		row.Link.RedirectCode = code
+	}
+	if value := field("max_clicks"); value != "" {
+		maxClicks, err := strconv.ParseUint(value, 10, 32)
+		check("max_clicks", err)
+		clicks := uint(maxClicks)
+		row.Link.MaxClicks = &clicks
+	}
+
+	var err error
+	row.Link.StartsAt, err = parseCsvTime(field("starts_at"))
+	check("starts_at", err)
+	row.Link.ExpiresAt, err = parseCsvTime(field("expires_at"))
+	check("expires_at", err)
+
+	return row
+}
+
+// parseImportJson reads an array of links one element at a time. An
+// element of the wrong shape fails its row, broken json the import.
+func parseImportJson(r io.Reader, limit int) ([]importRow, error) {
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, errors.New(ErrImportJson)
+	}
+
+	var rows []importRow
+	for decoder.More() && len(rows) < limit {
+		row := importRow{Row: len(rows) + 1}
+		if err := decoder.Decode(&row.Link); err != nil {
+			var syntaxError *json.SyntaxError
+			if errors.As(err, &syntaxError) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, err
+			}
+			row.Err = err
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// parseImportNdjson reads one link per line, skipping blank lines.
+func parseImportNdjson(r io.Reader, limit int) ([]importRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var rows []importRow
+	for line := 1; len(rows) < limit && scanner.Scan(); line++ {
+		data := scanner.Bytes()
+		if len(strings.TrimSpace(string(data))) == 0 {
+			continue
+		}
+
+		row := importRow{Row: line}
+		row.Err = json.Unmarshal(data, &row.Link)
+		rows = append(rows, row)
+	}
+
+	return rows, scanner.Err()
+}
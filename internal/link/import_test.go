@@ -0,0 +1,288 @@
+package link_test
+
+import (
+	"database/sql/driver"
+	"demo/go-server/internal/link"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestImportBitlyDryRun(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// Only the first row reaches the database; the second repeats its
+	// hash and the third has no valid url. A dry run writes nothing.
+	mock.ExpectQuery("count").WithArgs("abc123").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	csv := "Title,Long URL,Bitlink,Tags\n" +
+		"Sale,https://shop.test/sale,bit.ly/abc123,promo|summer\n" +
+		"Again,https://shop.test/again,bit.ly/abc123,\n" +
+		"Broken,not a url,bit.ly/xyz789,\n"
+	req := authedRequestWithBody(http.MethodPost, "/link/import?format=bitly&dry_run=true", strings.NewReader(csv))
+	req.Header.Set("Content-Type", "text/csv")
+	wr := httptest.NewRecorder()
+
+	handler.Import()(wr, req)
+	if wr.Code != http.StatusOK {
+		t.Fatalf("Got %d expected %d: %s", wr.Code, http.StatusOK, wr.Body.String())
+	}
+
+	var data link.LinkImportResponse
+	if err := json.NewDecoder(wr.Body).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	if !data.DryRun || data.Rows != 3 || data.Created != 1 || data.Skipped != 1 || data.Failed != 1 {
+		t.Errorf("Got %+v", data)
+	}
+	if len(data.Errors) != 1 || data.Errors[0].Row != 4 || data.Errors[0].Hash != "xyz789" {
+		t.Errorf("Got errors %+v", data.Errors)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestImportRenamesTakenHash(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	mock.ExpectQuery("count").WithArgs("sale").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("count").WithArgs("sale-2").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
+
+	body := `[{"hash":"sale","url":"https://shop.test/sale","active":false},{"hash":"sale","url":42}]`
+	req := authedRequestWithBody(http.MethodPost, "/link/import?on_conflict=rename", strings.NewReader(body))
+	wr := httptest.NewRecorder()
+
+	handler.Import()(wr, req)
+	if wr.Code != http.StatusOK {
+		t.Fatalf("Got %d expected %d: %s", wr.Code, http.StatusOK, wr.Body.String())
+	}
+
+	var data link.LinkImportResponse
+	if err := json.NewDecoder(wr.Body).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Created != 1 || data.Failed != 1 {
+		t.Errorf("Got %+v", data)
+	}
+	if len(data.Renamed) != 1 || data.Renamed[0].To != "sale-2" {
+		t.Errorf("Got renamed %+v", data.Renamed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestImportKeepsDisabledLinks(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	mock.ExpectQuery("count").WithArgs("off").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT").WithArgs(insertArgs(map[string]driver.Value{"active": false})...).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
+
+	body := `[{"hash":"off","url":"https://shop.test/off","active":false}]`
+	req := authedRequestWithBody(http.MethodPost, "/link/import", strings.NewReader(body))
+	wr := httptest.NewRecorder()
+
+	handler.Import()(wr, req)
+	if wr.Code != http.StatusOK {
+		t.Fatalf("Got %d expected %d: %s", wr.Code, http.StatusOK, wr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestImportOverwriteKeepsPasswordAndState(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	mock.ExpectQuery("count").WithArgs("sale").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	expectLinkByHash(mock, sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active", "password"}).
+		AddRow(5, "https://shop.test/old", "sale", 1, false, "secret-hash"))
+	expectLinkWithRelations(mock, sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active", "password"}).
+		AddRow(5, "https://shop.test/old", "sale", 1, false, "secret-hash"), sqlmock.NewRows([]string{"id"}), sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	// The password column is left out: the row has none. The row has no
+	// active column either, so the link stays disabled and only the url
+	// is recorded as changed.
+	mock.ExpectExec(`UPDATE "links" SET .*"max_clicks"=\$\d+ WHERE`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "routing_rules"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "variants"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE "links" SET "updated_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "link_tags"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FOR UPDATE").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery("MAX").WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(0))
+	mock.ExpectQuery(`INSERT INTO "link_revisions"`).
+		WithArgs(sqlmock.AnyArg(), 5, 1, 1, "a@a.com", "import", []byte(`["url"]`), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	body := `[{"hash":"sale","url":"https://shop.test/new"}]`
+	req := authedRequestWithBody(http.MethodPost, "/link/import?on_conflict=overwrite", strings.NewReader(body))
+	wr := httptest.NewRecorder()
+
+	handler.Import()(wr, req)
+	if wr.Code != http.StatusOK {
+		t.Fatalf("Got %d expected %d: %s", wr.Code, http.StatusOK, wr.Body.String())
+	}
+
+	var data link.LinkImportResponse
+	if err := json.NewDecoder(wr.Body).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Updated != 1 || data.Failed != 0 {
+		t.Errorf("Got %+v", data)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestImportRejectsTooManyRows(t *testing.T) {
+	handler, _, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	handler.Config.Link.ImportMaxRows = 1
+
+	body := `{"url":"https://a.com"}` + "\n" + `{"url":"https://b.com"}` + "\n"
+	req := authedRequestWithBody(http.MethodPost, "/link/import?format=ndjson", strings.NewReader(body))
+	wr := httptest.NewRecorder()
+
+	handler.Import()(wr, req)
+	if wr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Got %d expected %d", wr.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestExportCsv(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	handler.Config.Link.BaseUrl = "https://sho.rt"
+
+	mock.ExpectQuery(`FROM "links"`).WillReturnRows(sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active", "title"}).
+		AddRow(3, "https://shop.test", "qwerty", 1, true, "Example, Inc"))
+	mock.ExpectQuery("routing_rules").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("link_tags").WillReturnRows(sqlmock.NewRows([]string{"link_id", "tag_id"}))
+	mock.ExpectQuery("variants").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("sum").WillReturnRows(sqlmock.NewRows([]string{"link_id", "clicks"}).AddRow(3, 12))
+
+	req := authedRequest(http.MethodGet, "/link/export?format=csv")
+	wr := httptest.NewRecorder()
+
+	handler.Export()(wr, req)
+	if contentType := wr.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/csv") {
+		t.Errorf("Got content type %q", contentType)
+	}
+
+	lines := strings.Split(strings.TrimSpace(wr.Body.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "hash,short_url,url,") {
+		t.Fatalf("Got %q", wr.Body.String())
+	}
+	if !strings.HasPrefix(lines[1], `qwerty,https://sho.rt/qwerty,https://shop.test,,"Example, Inc",`) ||
+		!strings.Contains(lines[1], ",12,") {
+		t.Errorf("Got row %q", lines[1])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestImportPasswordHash(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	hashed := "$2a$04$abcdefghijklmnopqrstuuJ0D4cXbhqvE5ik0A9cUdjVnR2zqTzLa"
+	mock.ExpectQuery("count").WithArgs("kept").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT").WithArgs(insertArgs(map[string]driver.Value{"password": hashed})...).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
+
+	body := `[{"hash":"kept","url":"https://shop.test/a","protected":true,"password_hash":"` + hashed + `"},` +
+		`{"hash":"gone","url":"https://shop.test/b","protected":true},` +
+		`{"hash":"bad","url":"https://shop.test/c","password_hash":"plain"}]`
+	req := authedRequestWithBody(http.MethodPost, "/link/import", strings.NewReader(body))
+	wr := httptest.NewRecorder()
+
+	handler.Import()(wr, req)
+	if wr.Code != http.StatusOK {
+		t.Fatalf("Got %d expected %d: %s", wr.Code, http.StatusOK, wr.Body.String())
+	}
+
+	var data link.LinkImportResponse
+	if err := json.NewDecoder(wr.Body).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Created != 1 || data.Failed != 2 {
+		t.Errorf("Got %+v", data)
+	}
+	if len(data.Errors) != 2 || data.Errors[0].Error != link.ErrImportProtected || data.Errors[1].Error != link.ErrImportHash {
+		t.Errorf("Got errors %+v", data.Errors)
+	}
+}
+
+func TestExportCsvEscapesFormulas(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	mock.ExpectQuery(`FROM "links"`).WillReturnRows(sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active", "title", "password"}).
+		AddRow(3, "https://shop.test", "-sale", 1, true, `=HYPERLINK("https://evil.test")`, "$2a$04$hash"))
+	mock.ExpectQuery("routing_rules").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("link_tags").WillReturnRows(sqlmock.NewRows([]string{"link_id", "tag_id"}))
+	mock.ExpectQuery("variants").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("sum").WillReturnRows(sqlmock.NewRows([]string{"link_id", "clicks"}))
+
+	req := authedRequest(http.MethodGet, "/link/export?format=csv")
+	wr := httptest.NewRecorder()
+
+	handler.Export()(wr, req)
+	lines := strings.Split(strings.TrimSpace(wr.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Got %q", wr.Body.String())
+	}
+	if !strings.HasPrefix(lines[1], `'-sale,`) || !strings.Contains(lines[1], `,"'=HYPERLINK(""https://evil.test"")",`) {
+		t.Errorf("Got row %q", lines[1])
+	}
+	if !strings.Contains(lines[1], ",true,$2a$04$hash,0,") {
+		t.Errorf("Got row %q without the password gate", lines[1])
+	}
+}
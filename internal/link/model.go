@@ -1,39 +1,169 @@
 package link
 
 import (
+	"demo/go-server/internal/domain"
+	"demo/go-server/internal/folder"
 	"demo/go-server/internal/stat"
-	"math/rand/v2"
+	"demo/go-server/internal/tag"
+	"demo/go-server/internal/user"
+	"net/url"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 type Link struct {
 	gorm.Model
-	Url   string      `json:"url"`
-	Hash  string      `json:"hash" gorm:"uniqueIndex"`
-	Stats []stat.Stat `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	Url          string         `json:"url"`
+	Hash         string         `json:"hash" gorm:"uniqueIndex:idx_links_domain_hash,priority:2;uniqueIndex:idx_links_default_hash,where:domain_id IS NULL"`
+	DomainID     *uint          `json:"domain_id,omitempty" gorm:"uniqueIndex:idx_links_domain_hash,priority:1"`
+	Domain       *domain.Domain `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;"`
+	ShortUrl     string         `json:"short_url,omitempty" gorm:"-"`
+	Title        string         `json:"title"`
+	Description  string         `json:"description"`
+	Image        string         `json:"image"`
+	Favicon      string         `json:"favicon"`
+	UserID       uint           `json:"user_id" gorm:"index"`
+	User         *user.User     `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	FolderID     *uint          `json:"folder_id,omitempty" gorm:"index"`
+	Folder       *folder.Folder `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	Tags         []tag.Tag      `json:"tags" gorm:"many2many:link_tags;constraint:OnDelete:CASCADE;"`
+	Rules        []RoutingRule  `json:"rules,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Variants     []Variant      `json:"variants,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Active       bool           `json:"active" gorm:"not null"`
+	RedirectCode int            `json:"redirect_code" gorm:"not null;default:307"`
+	RedirectMode string         `json:"redirect_mode"`
+	ForwardQuery string         `json:"forward_query"`
+	UtmSource    string         `json:"utm_source,omitempty"`
+	UtmMedium    string         `json:"utm_medium,omitempty"`
+	UtmCampaign  string         `json:"utm_campaign,omitempty" gorm:"index"`
+	UtmTerm      string         `json:"utm_term,omitempty"`
+	UtmContent   string         `json:"utm_content,omitempty"`
+	AppLinks
+	StartsAt    *time.Time        `json:"starts_at,omitempty"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`
+	MaxClicks   *uint             `json:"max_clicks,omitempty"`
+	Password    string            `json:"-"`
+	Clicks      uint              `json:"clicks" gorm:"not null;default:0"`
+	TotalClicks *int64            `json:"total_clicks,omitempty" gorm:"->;-:migration"`
+	Stats       []stat.Stat       `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	ClickEvents []stat.ClickEvent `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 }
 
-func NewLink(url string) *Link {
-	link := &Link{
-		Url: url,
-	}
-	link.GenerateHash()
-	return link
+// RoutingRule sends visitors matching all of its conditions to Url
+// instead of the link's own url. Rules are evaluated by Position.
+type RoutingRule struct {
+	gorm.Model
+	LinkID   uint   `json:"-" gorm:"index"`
+	Position int    `json:"position"`
+	Platform string `json:"platform,omitempty"`
+	Language string `json:"language,omitempty"`
+	Country  string `json:"country,omitempty"`
+	FromHour *int   `json:"from_hour,omitempty"`
+	ToHour   *int   `json:"to_hour,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+	Url      string `json:"url"`
+}
+
+// Variant is one weighted destination of an A/B split link. Visits are
+// counted per variant name.
+type Variant struct {
+	gorm.Model
+	LinkID uint   `json:"-" gorm:"index"`
+	Name   string `json:"name"`
+	Url    string `json:"url"`
+	Weight int    `json:"weight"`
 }
 
-func (link *Link) GenerateHash() {
-	link.Hash = RandStringRunes(6)
+// AppLinks are the app URIs a link opens on phones, and the store pages
+// that visitors without the app get instead of the url.
+type AppLinks struct {
+	IosDeepLink     string `json:"ios_deep_link,omitempty"`
+	AndroidDeepLink string `json:"android_deep_link,omitempty"`
+	IosStoreUrl     string `json:"ios_store_url,omitempty"`
+	AndroidStoreUrl string `json:"android_store_url,omitempty"`
 }
 
-var letterRunes = []rune("qwertyuioplkjhgfdsazxcvbnmQWERTYUIOPLKJHGFDSAZXCVBNM")
+// LinkRevision records one change of a link: who made it, why, the
+// fields that changed and the link before and after.
+type LinkRevision struct {
+	ID        uint         `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time    `json:"created_at"`
+	LinkID    uint         `json:"link_id" gorm:"uniqueIndex:idx_link_revision_version"`
+	Link      *Link        `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Version   uint         `json:"version" gorm:"uniqueIndex:idx_link_revision_version"`
+	UserID    uint         `json:"user_id"`
+	Author    string       `json:"author"`
+	Reason    string       `json:"reason,omitempty"`
+	Fields    FieldList    `json:"fields" gorm:"type:jsonb"`
+	Old       LinkSnapshot `json:"old" gorm:"type:jsonb"`
+	New       LinkSnapshot `json:"new" gorm:"type:jsonb"`
+}
+
+// LinkHealth is the outcome of the latest check of a link's url.
+type LinkHealth struct {
+	LinkID     uint      `json:"link_id" gorm:"primarykey;autoIncrement:false"`
+	Link       *Link     `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	StatusCode int       `json:"status_code,omitempty"`
+	LatencyMs  int64     `json:"latency_ms"`
+	Error      string    `json:"error,omitempty"`
+	Broken     bool      `json:"broken" gorm:"index"`
+	Failures   int       `json:"failures"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
 
-func RandStringRunes(n int) string {
-	b := make([]rune, n)
+// LinkTemplate is a stored create request whose strings may contain
+// {{name}} placeholders, filled in from one row of values per link.
+type LinkTemplate struct {
+	gorm.Model
+	UserID    uint              `json:"user_id" gorm:"index"`
+	User      *user.User        `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Name      string            `json:"name"`
+	Link      LinkCreateRequest `json:"link" gorm:"type:jsonb;serializer:json"`
+	Variables []string          `json:"variables" gorm:"-"`
+}
 
-	for i := range b {
-		b[i] = letterRunes[rand.IntN(n)]
+func NewLink(url string, userID uint) *Link {
+	return &Link{
+		Url:    url,
+		UserID: userID,
+		Active: true,
 	}
+}
+
+// IsLive reports whether the link is enabled and its scheduled start,
+// if any, has passed.
+func (link *Link) IsLive(now time.Time) bool {
+	return link.Active && (link.StartsAt == nil || !now.Before(*link.StartsAt))
+}
+
+// IsExpired reports whether the link is past its expiry date or has
+// already used up its click budget.
+func (link *Link) IsExpired(now time.Time) bool {
+	if link.ExpiresAt != nil && !now.Before(*link.ExpiresAt) {
+		return true
+	}
+	return link.MaxClicks != nil && link.Clicks >= *link.MaxClicks
+}
+
+// UtmParams returns the link's UTM fields that are set, keyed by their
+// query parameter names.
+func (link *Link) UtmParams() url.Values {
+	params := url.Values{}
+	for key, value := range map[string]string{
+		"utm_source":   link.UtmSource,
+		"utm_medium":   link.UtmMedium,
+		"utm_campaign": link.UtmCampaign,
+		"utm_term":     link.UtmTerm,
+		"utm_content":  link.UtmContent,
+	} {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+	return params
+}
 
-	return string(b)
+func (link *Link) IsProtected() bool {
+	return link.Password != ""
 }
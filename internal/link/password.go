@@ -0,0 +1,171 @@
+package link
+
+import (
+	"html/template"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const PasswordHeader = "X-Link-Password"
+
+var unlockTemplate = template.Must(template.New("unlock").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Protected link</title></head>
+<body>
+<form method="post" action="/{{.Hash}}">
+<p>This link is password protected.</p>
+{{if .Error}}<p>{{.Error}}</p>{{end}}
+<input type="password" name="password" autofocus>
+<button type="submit">Open</button>
+</form>
+</body>
+</html>
+`))
+
+type unlockPage struct {
+	Hash  string
+	Error string
+}
+
+func writeUnlockForm(w http.ResponseWriter, hash, message string, statusCode int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(statusCode)
+	_ = unlockTemplate.Execute(w, unlockPage{Hash: hash, Error: message})
+}
+
+func hashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// Defaults used by NewAttemptLimiter when the configured limits or window
+// aren't positive, so a bad setting can't lock every protected link.
+const (
+	defaultMaxAttempts     = 5
+	defaultLinkMaxAttempts = 50
+	defaultAttemptWindow   = 15 * time.Minute
+)
+
+// AttemptLimiter counts failed unlock attempts inside a fixed window and
+// refuses further attempts once a limit is reached. Each client gets max
+// failures per link, and every link has a ceiling of linkMax failures
+// across all clients, so rotating addresses doesn't buy more guesses.
+type AttemptLimiter struct {
+	mu        sync.Mutex
+	max       int
+	linkMax   int
+	window    time.Duration
+	attempts  map[attemptKey]*attemptWindow
+	lastSweep time.Time
+}
+
+// attemptKey identifies the failures of one client on a link; an empty
+// client holds the failures of all clients.
+type attemptKey struct {
+	linkID uint
+	client string
+}
+
+type attemptWindow struct {
+	failures int
+	start    time.Time
+}
+
+func NewAttemptLimiter(limit, linkLimit int, window time.Duration) *AttemptLimiter {
+	if limit <= 0 {
+		limit = defaultMaxAttempts
+	}
+	if linkLimit <= 0 {
+		linkLimit = defaultLinkMaxAttempts
+	}
+	if window <= 0 {
+		window = defaultAttemptWindow
+	}
+	return &AttemptLimiter{
+		max:       limit,
+		linkMax:   max(linkLimit, limit),
+		window:    window,
+		attempts:  make(map[attemptKey]*attemptWindow),
+		lastSweep: time.Now(),
+	}
+}
+
+// clientKey keys IPv6 clients by their /64, which a single host usually
+// holds in full.
+func clientKey(client net.IP) string {
+	if client.To4() == nil && len(client) == net.IPv6len {
+		return client.Mask(net.CIDRMask(64, 128)).String()
+	}
+	return client.String()
+}
+
+func (l *AttemptLimiter) Allow(linkID uint, client net.IP) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	return l.failures(attemptKey{linkID: linkID}, now) < l.linkMax &&
+		l.failures(attemptKey{linkID: linkID, client: clientKey(client)}, now) < l.max
+}
+
+func (l *AttemptLimiter) Fail(linkID uint, client net.IP) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+	l.count(attemptKey{linkID: linkID}, now)
+	l.count(attemptKey{linkID: linkID, client: clientKey(client)}, now)
+}
+
+// Reset clears the failures of a client that unlocked the link. The
+// link's own count is left to expire, or a correct guess from one
+// address would clear the way for others.
+func (l *AttemptLimiter) Reset(linkID uint, client net.IP) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.attempts, attemptKey{linkID: linkID, client: clientKey(client)})
+}
+
+func (l *AttemptLimiter) failures(key attemptKey, now time.Time) int {
+	attempt, ok := l.attempts[key]
+	if !ok {
+		return 0
+	}
+	if now.Sub(attempt.start) > l.window {
+		delete(l.attempts, key)
+		return 0
+	}
+	return attempt.failures
+}
+
+func (l *AttemptLimiter) count(key attemptKey, now time.Time) {
+	attempt, ok := l.attempts[key]
+	if !ok || now.Sub(attempt.start) > l.window {
+		attempt = &attemptWindow{start: now}
+		l.attempts[key] = attempt
+	}
+	attempt.failures++
+}
+
+// sweep drops expired windows at most once per window so clients that
+// never come back don't keep their entries forever.
+func (l *AttemptLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	l.lastSweep = now
+	for key, attempt := range l.attempts {
+		if now.Sub(attempt.start) > l.window {
+			delete(l.attempts, key)
+		}
+	}
+}
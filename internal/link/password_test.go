@@ -0,0 +1,79 @@
+package link_test
+
+import (
+	"demo/go-server/internal/link"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAttemptLimiterPerClient(t *testing.T) {
+	limiter := link.NewAttemptLimiter(1, 10, time.Minute)
+	attacker := net.ParseIP("203.0.113.7")
+	visitor := net.ParseIP("198.51.100.2")
+
+	limiter.Fail(5, attacker)
+	if limiter.Allow(5, attacker) {
+		t.Error("Client over the limit is still allowed")
+	}
+	if !limiter.Allow(5, visitor) {
+		t.Error("Another client is locked out by someone else's failures")
+	}
+	if !limiter.Allow(6, attacker) {
+		t.Error("Failures on one link lock another link")
+	}
+
+	limiter.Reset(5, attacker)
+	if !limiter.Allow(5, attacker) {
+		t.Error("Reset client is still locked out")
+	}
+}
+
+func TestAttemptLimiterDefaultsNonPositiveMax(t *testing.T) {
+	limiter := link.NewAttemptLimiter(0, 0, 0)
+	client := net.ParseIP("203.0.113.7")
+
+	if !limiter.Allow(5, client) {
+		t.Error("A zero limit locks the link before any attempt")
+	}
+	limiter.Fail(5, client)
+	if !limiter.Allow(5, client) {
+		t.Error("A zero limit locks the link after one attempt")
+	}
+}
+
+func TestAttemptLimiterPerLinkCeiling(t *testing.T) {
+	limiter := link.NewAttemptLimiter(2, 3, time.Minute)
+
+	for i := range 3 {
+		client := net.IPv4(203, 0, 113, byte(i+1))
+		if !limiter.Allow(5, client) {
+			t.Fatalf("Client %d blocked before the ceiling", i)
+		}
+		limiter.Fail(5, client)
+	}
+	if limiter.Allow(5, net.ParseIP("198.51.100.2")) {
+		t.Error("A fresh client is allowed past the link ceiling")
+	}
+
+	// A successful unlock doesn't lift the ceiling for everyone else.
+	limiter.Reset(5, net.IPv4(203, 0, 113, 1))
+	if limiter.Allow(5, net.ParseIP("198.51.100.2")) {
+		t.Error("Reset lifted the link ceiling")
+	}
+	if !limiter.Allow(6, net.ParseIP("198.51.100.2")) {
+		t.Error("The ceiling of one link blocks another")
+	}
+}
+
+func TestAttemptLimiterGroupsIPv6Prefix(t *testing.T) {
+	limiter := link.NewAttemptLimiter(1, 10, time.Minute)
+
+	limiter.Fail(5, net.ParseIP("2001:db8:1:2::a"))
+	if limiter.Allow(5, net.ParseIP("2001:db8:1:2:ffff::1")) {
+		t.Error("Another address in the same /64 is allowed")
+	}
+	if !limiter.Allow(5, net.ParseIP("2001:db8:1:3::a")) {
+		t.Error("Another /64 is blocked")
+	}
+}
@@ -1,15 +1,187 @@
 package link
 
+import "time"
+
 type LinkCreateRequest struct {
-	Url string `json:"url" validate:"required,url"`
+	Url          string               `json:"url" validate:"required,url"`
+	Alias        string               `json:"alias,omitempty"`
+	Title        string               `json:"title,omitempty" validate:"max=255"`
+	Description  string               `json:"description,omitempty" validate:"max=2000"`
+	Tags         []string             `json:"tags,omitempty" validate:"max=20,dive,required,max=64"`
+	FolderID     *uint                `json:"folder_id,omitempty"`
+	DomainID     *uint                `json:"domain_id,omitempty"`
+	Rules        []RoutingRuleRequest `json:"rules,omitempty" validate:"max=20,dive"`
+	Variants     []VariantRequest     `json:"variants,omitempty" validate:"max=10,unique=Name,dive"`
+	StartsAt     *time.Time           `json:"starts_at,omitempty"`
+	RedirectCode int                  `json:"redirect_code,omitempty" validate:"omitempty,oneof=301 302 307 308"`
+	RedirectMode string               `json:"redirect_mode,omitempty" validate:"omitempty,oneof=direct interstitial meta"`
+	ForwardQuery string               `json:"forward_query,omitempty" validate:"omitempty,oneof=none all utm"`
+	ExpiresAt    *time.Time           `json:"expires_at,omitempty"`
+	MaxClicks    *uint                `json:"max_clicks,omitempty" validate:"omitempty,min=1"`
+	Password     string               `json:"password,omitempty" validate:"omitempty,min=4"`
+	UtmSource    string               `json:"utm_source,omitempty" validate:"max=255"`
+	UtmMedium    string               `json:"utm_medium,omitempty" validate:"max=255"`
+	UtmCampaign  string               `json:"utm_campaign,omitempty" validate:"max=255"`
+	UtmTerm      string               `json:"utm_term,omitempty" validate:"max=255"`
+	UtmContent   string               `json:"utm_content,omitempty" validate:"max=255"`
+	AppLinksRequest
 }
 
 type LinkUpdateRequest struct {
-	Url  string `json:"url" validate:"required,url"`
-	Hash string `json:"hash"`
+	Url          string                `json:"url" validate:"required,url"`
+	Hash         string                `json:"hash"`
+	Title        string                `json:"title,omitempty" validate:"max=255"`
+	Description  string                `json:"description,omitempty" validate:"max=2000"`
+	Tags         *[]string             `json:"tags,omitempty" validate:"omitempty,max=20,dive,required,max=64"`
+	FolderID     *uint                 `json:"folder_id,omitempty"`
+	Rules        *[]RoutingRuleRequest `json:"rules,omitempty" validate:"omitempty,max=20,dive"`
+	Variants     *[]VariantRequest     `json:"variants,omitempty" validate:"omitempty,max=10,unique=Name,dive"`
+	RedirectCode int                   `json:"redirect_code,omitempty" validate:"omitempty,oneof=301 302 307 308"`
+	RedirectMode string                `json:"redirect_mode,omitempty" validate:"omitempty,oneof=direct interstitial meta"`
+	ForwardQuery string                `json:"forward_query,omitempty" validate:"omitempty,oneof=none all utm"`
+	UtmSource    string                `json:"utm_source,omitempty" validate:"max=255"`
+	UtmMedium    string                `json:"utm_medium,omitempty" validate:"max=255"`
+	UtmCampaign  string                `json:"utm_campaign,omitempty" validate:"max=255"`
+	UtmTerm      string                `json:"utm_term,omitempty" validate:"max=255"`
+	UtmContent   string                `json:"utm_content,omitempty" validate:"max=255"`
+	AppLinksRequest
+	Reason string `json:"reason,omitempty" validate:"max=500"`
+}
+
+// AppLinksRequest sets the deep links of a link. Deep links may use any
+// scheme but script ones; store urls are checked like destinations.
+type AppLinksRequest struct {
+	IosDeepLink     string `json:"ios_deep_link,omitempty" validate:"omitempty,uri,max=2048"`
+	AndroidDeepLink string `json:"android_deep_link,omitempty" validate:"omitempty,uri,max=2048"`
+	IosStoreUrl     string `json:"ios_store_url,omitempty" validate:"omitempty,url,max=2048"`
+	AndroidStoreUrl string `json:"android_store_url,omitempty" validate:"omitempty,url,max=2048"`
+}
+
+type VariantRequest struct {
+	Name   string `json:"name" validate:"required,max=64"`
+	Url    string `json:"url" validate:"required,url"`
+	Weight int    `json:"weight" validate:"required,min=1,max=1000"`
+}
+
+type RoutingRuleRequest struct {
+	Url      string `json:"url" validate:"required,url"`
+	Platform string `json:"platform,omitempty" validate:"omitempty,oneof=ios android desktop"`
+	Language string `json:"language,omitempty" validate:"omitempty,alpha,min=2,max=3"`
+	Country  string `json:"country,omitempty" validate:"omitempty,iso3166_1_alpha2"`
+	FromHour *int   `json:"from_hour,omitempty" validate:"required_with=ToHour,omitempty,min=0,max=23"`
+	ToHour   *int   `json:"to_hour,omitempty" validate:"required_with=FromHour,omitempty,min=0,max=23"`
+	Timezone string `json:"timezone,omitempty" validate:"omitempty,timezone"`
+}
+
+type LinkStatusRequest struct {
+	Active   *bool      `json:"active" validate:"required"`
+	StartsAt *time.Time `json:"starts_at"`
+	Reason   string     `json:"reason,omitempty" validate:"max=500"`
+}
+
+type LinkCloneRequest struct {
+	Alias string `json:"alias,omitempty"`
+}
+
+// LinkTemplateRequest leaves Link unvalidated: its placeholders are
+// checked with sample values instead.
+type LinkTemplateRequest struct {
+	Name string            `json:"name" validate:"required,max=128"`
+	Link LinkCreateRequest `json:"link" validate:"-"`
+}
+
+type LinkTemplateGenerateRequest struct {
+	Rows []map[string]string `json:"rows" validate:"required,min=1"`
+}
+
+type LinkRevertRequest struct {
+	Reason string `json:"reason,omitempty" validate:"max=500"`
+}
+
+// LinkExport is one link in an export, and one row of an import.
+// Password is never exported, but an import may set it.
+type LinkExport struct {
+	Hash         string               `json:"hash"`
+	ShortUrl     string               `json:"short_url,omitempty"`
+	Url          string               `json:"url"`
+	Domain       string               `json:"domain,omitempty"`
+	Title        string               `json:"title,omitempty"`
+	Description  string               `json:"description,omitempty"`
+	Tags         []string             `json:"tags,omitempty"`
+	Folder       string               `json:"folder,omitempty"`
+	Active       *bool                `json:"active,omitempty"`
+	RedirectCode int                  `json:"redirect_code,omitempty"`
+	RedirectMode string               `json:"redirect_mode,omitempty"`
+	ForwardQuery string               `json:"forward_query,omitempty"`
+	Rules        []RoutingRuleRequest `json:"rules,omitempty"`
+	Variants     []VariantRequest     `json:"variants,omitempty"`
+	UtmSource    string               `json:"utm_source,omitempty"`
+	UtmMedium    string               `json:"utm_medium,omitempty"`
+	UtmCampaign  string               `json:"utm_campaign,omitempty"`
+	UtmTerm      string               `json:"utm_term,omitempty"`
+	UtmContent   string               `json:"utm_content,omitempty"`
+	AppLinksRequest
+	StartsAt  *time.Time `json:"starts_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	MaxClicks *uint      `json:"max_clicks,omitempty"`
+	Password  string     `json:"password,omitempty"`
+	// Protected and PasswordHash carry the password gate through an
+	// export, so a re-import restores it instead of dropping it.
+	Protected    bool       `json:"protected,omitempty"`
+	PasswordHash string     `json:"password_hash,omitempty"`
+	Clicks       int64      `json:"clicks"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+}
+
+type LinkImportResponse struct {
+	DryRun  bool               `json:"dry_run"`
+	Rows    int                `json:"rows"`
+	Created int                `json:"created"`
+	Updated int                `json:"updated"`
+	Skipped int                `json:"skipped"`
+	Failed  int                `json:"failed"`
+	Renamed []LinkImportRename `json:"renamed"`
+	Errors  []LinkImportError  `json:"errors"`
+}
+
+// LinkImportRename tells which hash a row got when its own was taken.
+type LinkImportRename struct {
+	Row  int    `json:"row"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type LinkImportError struct {
+	Row    int    `json:"row"`
+	Hash   string `json:"hash,omitempty"`
+	Status int    `json:"status"`
+	Error  string `json:"error"`
 }
 
 type GetAllLinksResponse struct {
 	Links []Link `json:"links"`
-	Count int64  `json:"count"`
+	Next  string `json:"next,omitempty"`
+	Count *int64 `json:"count,omitempty"`
+}
+
+type LinkBulkResult struct {
+	Index  int    `json:"index"`
+	Status int    `json:"status"`
+	Link   *Link  `json:"link,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type LinkBulkCreateResponse struct {
+	Results []LinkBulkResult `json:"results"`
+	Created int              `json:"created"`
+	Failed  int              `json:"failed"`
+}
+
+type LinkBulkDeleteRequest struct {
+	Ids []uint `json:"ids" validate:"required,min=1"`
+}
+
+type LinkBulkDeleteResponse struct {
+	Deleted  []uint `json:"deleted"`
+	NotFound []uint `json:"not_found"`
 }
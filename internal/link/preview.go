@@ -0,0 +1,143 @@
+package link
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Preview is what PreviewFetcher extracts from the head of a page.
+type Preview struct {
+	Title       string
+	Description string
+	Image       string
+	Favicon     string
+}
+
+type PreviewFetcher struct {
+	Client   *http.Client
+	MaxBytes int64
+}
+
+func NewPreviewFetcher(timeout time.Duration, maxBytes int64, maxRedirects int) *PreviewFetcher {
+	return &PreviewFetcher{
+		Client: &http.Client{
+			Timeout: timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) > maxRedirects {
+					return errors.New(ErrTooManyRedirects)
+				}
+				return nil
+			},
+		},
+		MaxBytes: maxBytes,
+	}
+}
+
+func (f *PreviewFetcher) Fetch(ctx context.Context, target string) (*Preview, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html")
+
+	res, err := f.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		return nil, fmt.Errorf("%s: %s", target, res.Status)
+	}
+	if contentType := res.Header.Get("Content-Type"); contentType != "" && !strings.Contains(contentType, "html") {
+		return nil, errors.New(ErrNotHtml)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, f.MaxBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePreview(string(body), res.Request.URL), nil
+}
+
+var (
+	titlePattern     = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	tagPattern       = regexp.MustCompile(`(?is)<(meta|link)\s[^>]*>`)
+	attributePattern = regexp.MustCompile(`(?is)([a-z][a-z0-9:_-]*)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+func parsePreview(document string, base *url.URL) *Preview {
+	preview := &Preview{}
+
+	if match := titlePattern.FindStringSubmatch(document); match != nil {
+		preview.Title = cleanText(match[1])
+	}
+
+	for _, tag := range tagPattern.FindAllStringSubmatch(document, -1) {
+		attrs := parseAttributes(tag[0])
+
+		switch strings.ToLower(tag[1]) {
+		case "meta":
+			name := strings.ToLower(attrs["property"])
+			if name == "" {
+				name = strings.ToLower(attrs["name"])
+			}
+			switch name {
+			case "og:title":
+				preview.Title = cleanText(attrs["content"])
+			case "og:description":
+				preview.Description = cleanText(attrs["content"])
+			case "description":
+				if preview.Description == "" {
+					preview.Description = cleanText(attrs["content"])
+				}
+			case "og:image":
+				preview.Image = resolveUrl(base, attrs["content"])
+			}
+		case "link":
+			for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+				if rel == "icon" && preview.Favicon == "" {
+					preview.Favicon = resolveUrl(base, attrs["href"])
+				}
+			}
+		}
+	}
+
+	if preview.Favicon == "" {
+		preview.Favicon = resolveUrl(base, "/favicon.ico")
+	}
+
+	return preview
+}
+
+func parseAttributes(tag string) map[string]string {
+	attrs := make(map[string]string)
+	for _, match := range attributePattern.FindAllStringSubmatch(tag, -1) {
+		attrs[strings.ToLower(match[1])] = html.UnescapeString(match[2] + match[3] + match[4])
+	}
+	return attrs
+}
+
+func cleanText(text string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+func resolveUrl(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	parsed, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return ""
+	}
+	return base.ResolveReference(parsed).String()
+}
@@ -0,0 +1,91 @@
+package link_test
+
+import (
+	"context"
+	"demo/go-server/internal/link"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const previewPage = `<!DOCTYPE html>
+<html><head>
+<title>
+  Spring  sale &amp; more
+</title>
+<meta content="Everything half price" property="og:description">
+<meta property='og:image' content='/img/cover.png'>
+<link rel="shortcut icon" href="/static/icon.png">
+</head><body></body></html>`
+
+func TestPreviewFetcher(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/moved":
+			http.Redirect(w, r, "/page", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+			fmt.Fprint(w, previewPage)
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, previewPage)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	fetcher := link.NewPreviewFetcher(100*time.Millisecond, 1024*1024, 2)
+
+	preview, err := fetcher.Fetch(context.Background(), ts.URL+"/moved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Title != "Spring sale & more" {
+		t.Errorf("Got title %q", preview.Title)
+	}
+	if preview.Description != "Everything half price" {
+		t.Errorf("Got description %q", preview.Description)
+	}
+	if preview.Image != ts.URL+"/img/cover.png" {
+		t.Errorf("Got image %q", preview.Image)
+	}
+	if preview.Favicon != ts.URL+"/static/icon.png" {
+		t.Errorf("Got favicon %q", preview.Favicon)
+	}
+
+	if _, err := fetcher.Fetch(context.Background(), ts.URL+"/loop"); err == nil {
+		t.Error("Expected redirect limit error")
+	}
+	if _, err := fetcher.Fetch(context.Background(), ts.URL+"/slow"); err == nil {
+		t.Error("Expected timeout error")
+	}
+	if _, err := fetcher.Fetch(context.Background(), ts.URL+"/missing"); err == nil {
+		t.Error("Expected error for 404")
+	}
+}
+
+func TestPreviewFetcherSizeLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><head>"+strings.Repeat(" ", 4096)+"<title>Too far</title>")
+	}))
+	defer ts.Close()
+
+	fetcher := link.NewPreviewFetcher(time.Second, 1024, 2)
+	preview, err := fetcher.Fetch(context.Background(), ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Title != "" {
+		t.Errorf("Got title %q beyond the size limit", preview.Title)
+	}
+	if preview.Favicon != ts.URL+"/favicon.ico" {
+		t.Errorf("Got favicon %q", preview.Favicon)
+	}
+}
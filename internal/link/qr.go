@@ -0,0 +1,167 @@
+package link
+
+import (
+	"crypto/sha256"
+	"demo/go-server/pkg/qr"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image/color"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	qrDefaultSize = 256
+	qrMinSize     = 64
+	qrMaxSize     = 2048
+)
+
+// QrCode renders the short url of an owned link.
+func (handler *LinkHandler) QrCode() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, ok := handler.currentUserID(w, req)
+		if !ok {
+			return
+		}
+		link, ok := handler.ownedLink(w, req, userID)
+		if !ok {
+			return
+		}
+
+		handler.present(req, link)
+		handler.writeQr(w, req, link.ShortUrl, "private, max-age=86400")
+	}
+}
+
+// PublicQrCode renders the short url of any existing hash.
+func (handler *LinkHandler) PublicQrCode() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		link, err := handler.LinkRepository.GetByHash(handler.requestDomainID(req), req.PathValue("hash"))
+		if err != nil {
+			http.Error(w, ErrLinkNotFound, http.StatusNotFound)
+			return
+		}
+
+		handler.present(req, link)
+		handler.writeQr(w, req, link.ShortUrl, "public, max-age=86400")
+	}
+}
+
+// writeQr renders content as asked by the query, answering 304 when the
+// client already holds the same image. cacheControl tells shared caches
+// whether they may keep it.
+func (handler *LinkHandler) writeQr(w http.ResponseWriter, req *http.Request, content, cacheControl string) {
+	format, opts, err := handler.qrOptions(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	logo := ""
+	if opts.Logo != nil {
+		logo = handler.QrLogo.Digest
+	}
+	etag := qrEtag(content, format, req.URL.Query(), logo)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControl)
+	if match := req.Header.Get("If-None-Match"); match != "" && strings.Contains(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var image []byte
+	if format == qr.FormatSVG {
+		image, err = qr.SVG(content, opts)
+		w.Header().Set("Content-Type", "image/svg+xml")
+	} else {
+		image, err = qr.PNG(content, opts)
+		w.Header().Set("Content-Type", "image/png")
+	}
+	if err != nil {
+		w.Header().Del("Content-Type")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(image)
+}
+
+// qrOptions reads format, size, level, fg, bg and logo query params.
+func (handler *LinkHandler) qrOptions(req *http.Request) (string, qr.Options, error) {
+	query := req.URL.Query()
+	opts := qr.Options{
+		Size:       qrDefaultSize,
+		Foreground: color.RGBA{A: 0xff},
+		Background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+
+	format := query.Get("format")
+	switch format {
+	case "":
+		format = qr.FormatPNG
+	case qr.FormatPNG, qr.FormatSVG:
+	default:
+		return "", opts, errors.New(ErrQrFormat)
+	}
+
+	if value := query.Get("size"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < qrMinSize || size > qrMaxSize {
+			return "", opts, fmt.Errorf(ErrQrSize, qrMinSize, qrMaxSize)
+		}
+		opts.Size = size
+	}
+
+	if value := query.Get("logo"); value != "" {
+		withLogo, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", opts, errors.New(ErrQrLogo)
+		}
+		if withLogo {
+			if handler.QrLogo == nil {
+				return "", opts, errors.New(ErrQrNoLogo)
+			}
+			opts.Logo = handler.QrLogo.Image
+		}
+	}
+
+	// A logo hides modules in the middle, so default to the level that
+	// recovers the most of them.
+	level := "M"
+	if opts.Logo != nil {
+		level = "H"
+	}
+	if value := query.Get("level"); value != "" {
+		level = value
+	}
+	var ok bool
+	if opts.Level, ok = qr.ParseLevel(level); !ok {
+		return "", opts, errors.New(ErrQrLevel)
+	}
+
+	var err error
+	if value := query.Get("fg"); value != "" {
+		if opts.Foreground, err = qr.ParseColor(value); err != nil {
+			return "", opts, err
+		}
+	}
+	if value := query.Get("bg"); value != "" {
+		if opts.Background, err = qr.ParseColor(value); err != nil {
+			return "", opts, err
+		}
+	}
+
+	return format, opts, nil
+}
+
+// qrEtag hashes everything the image depends on; logo is the digest of
+// the logo file, empty without one.
+func qrEtag(content, format string, query map[string][]string, logo string) string {
+	sum := sha256.New()
+	fmt.Fprintf(sum, "%s|%s|%s|", content, format, logo)
+	for _, key := range []string{"size", "level", "fg", "bg"} {
+		fmt.Fprintf(sum, "%s=%v|", key, query[key])
+	}
+	return `"` + hex.EncodeToString(sum.Sum(nil)[:16]) + `"`
+}
@@ -0,0 +1,133 @@
+package link_test
+
+import (
+	"bytes"
+	"demo/go-server/pkg/qr"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPublicQrCode(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	rows := sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active"}).
+		AddRow(5, "https://a.com", "qwerty", 1, true)
+	expectLinkByHash(mock, rows)
+
+	wr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/qwerty/qr?size=128", nil)
+	req.SetPathValue("hash", "qwerty")
+
+	handler.PublicQrCode()(wr, req)
+	if wr.Code != http.StatusOK {
+		t.Fatalf("Got %d expected %d", wr.Code, http.StatusOK)
+	}
+	img, err := png.Decode(bytes.NewReader(wr.Body.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 128 {
+		t.Errorf("Got width %d expected 128", img.Bounds().Dx())
+	}
+
+	// The same request with the returned ETag is answered from cache.
+	expectLinkByHash(mock, sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active"}).
+		AddRow(5, "https://a.com", "qwerty", 1, true))
+	cached := httptest.NewRecorder()
+	req.Header.Set("If-None-Match", wr.Header().Get("ETag"))
+	handler.PublicQrCode()(cached, req)
+	if cached.Code != http.StatusNotModified {
+		t.Errorf("Got %d expected %d", cached.Code, http.StatusNotModified)
+	}
+}
+
+func TestQrCodeOptions(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	for query, code := range map[string]int{
+		"format=svg&fg=c00&bg=ffffff&level=H": http.StatusOK,
+		"format=gif":                          http.StatusBadRequest,
+		"size=10":                             http.StatusBadRequest,
+		"fg=red":                              http.StatusBadRequest,
+		"logo=true":                           http.StatusBadRequest,
+	} {
+		expectLinkByHash(mock, sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active"}).
+			AddRow(5, "https://a.com", "qwerty", 1, true))
+		wr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/qwerty/qr?"+query, nil)
+		req.SetPathValue("hash", "qwerty")
+
+		handler.PublicQrCode()(wr, req)
+		if wr.Code != code {
+			t.Errorf("%s: got %d expected %d", query, wr.Code, code)
+		}
+		if code == http.StatusOK && !strings.HasPrefix(wr.Body.String(), "<svg") {
+			t.Errorf("%s: expected an svg body", query)
+		}
+	}
+}
+
+func TestQrCodePrivateCache(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active"}).
+		AddRow(5, "https://a.com", "qwerty", 1, true))
+
+	wr := httptest.NewRecorder()
+	req := authedRequest(http.MethodGet, "/link/5/qr")
+	req.SetPathValue("id", "5")
+
+	handler.QrCode()(wr, req)
+	if wr.Code != http.StatusOK {
+		t.Fatalf("Got %d expected %d", wr.Code, http.StatusOK)
+	}
+	if cache := wr.Header().Get("Cache-Control"); !strings.HasPrefix(cache, "private") {
+		t.Errorf("Got Cache-Control %q expected private", cache)
+	}
+}
+
+func TestQrCodeEtagFollowsLogo(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	handler.QrLogo = &qr.Logo{Image: image.NewRGBA(image.Rect(0, 0, 8, 8)), Digest: "old"}
+
+	etags := make([]string, 0, 2)
+	for _, digest := range []string{"old", "new"} {
+		handler.QrLogo.Digest = digest
+		expectLinkByHash(mock, sqlmock.NewRows([]string{"id", "url", "hash", "user_id", "active"}).
+			AddRow(5, "https://a.com", "qwerty", 1, true))
+		wr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/qwerty/qr?logo=true", nil)
+		req.SetPathValue("hash", "qwerty")
+
+		handler.PublicQrCode()(wr, req)
+		if wr.Code != http.StatusOK {
+			t.Fatalf("Got %d expected %d", wr.Code, http.StatusOK)
+		}
+		etags = append(etags, wr.Header().Get("ETag"))
+	}
+	if etags[0] == etags[1] {
+		t.Error("ETag did not change with the logo")
+	}
+}
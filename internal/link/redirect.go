@@ -0,0 +1,157 @@
+package link
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	RedirectModeDirect       = "direct"
+	RedirectModeInterstitial = "interstitial"
+	RedirectModeMeta         = "meta"
+
+	ForwardQueryNone = "none"
+	ForwardQueryAll  = "all"
+	ForwardQueryUtm  = "utm"
+)
+
+var interstitialTemplate = template.Must(template.New("interstitial").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="robots" content="noindex"><title>You are leaving</title></head>
+<body>
+<p>You are leaving for <strong>{{.Host}}</strong>.</p>
+<p><a href="{{.Url}}" rel="noreferrer">Continue to {{.Url}}</a></p>
+</body>
+</html>
+`))
+
+var metaRefreshTemplate = template.Must(template.New("meta").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="0;url={{.Url}}">
+<title>Redirecting</title>
+<script>window.location.replace({{.Url}});</script>
+</head>
+<body><p><a href="{{.Url}}">Continue</a></p></body>
+</html>
+`))
+
+type redirectPage struct {
+	Url  string
+	Host string
+}
+
+// redirect sends the visitor to destination the way the link asks for:
+// a Location header with its status code, an interstitial page or a meta
+// refresh page for clients that drop Location headers.
+func redirect(w http.ResponseWriter, req *http.Request, link *Link, destination string) {
+	switch link.RedirectMode {
+	case RedirectModeInterstitial, RedirectModeMeta:
+		page := redirectPage{Url: destination}
+		if parsed, err := url.Parse(destination); err == nil {
+			page.Host = parsed.Host
+		}
+		tmpl := metaRefreshTemplate
+		if link.RedirectMode == RedirectModeInterstitial {
+			tmpl = interstitialTemplate
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		_ = tmpl.Execute(w, page)
+	default:
+		http.Redirect(w, req, destination, link.redirectCode(req))
+	}
+}
+
+// redirectCode returns the configured status code. A form post, as sent
+// by the unlock page, is answered with 303 so the browser does not post
+// the password on to the destination.
+func (link *Link) redirectCode(req *http.Request) int {
+	if req.Method == http.MethodPost {
+		return http.StatusSeeOther
+	}
+	switch link.RedirectCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return link.RedirectCode
+	default:
+		return http.StatusTemporaryRedirect
+	}
+}
+
+// forwardQuery copies the incoming query parameters selected by the
+// link's ForwardQuery setting onto destination.
+func (link *Link) forwardQuery(destination string, incoming url.Values) string {
+	switch link.ForwardQuery {
+	case ForwardQueryAll:
+		return mergeQuery(destination, incoming)
+	case ForwardQueryUtm:
+		utm := url.Values{}
+		for key, values := range incoming {
+			if strings.HasPrefix(strings.ToLower(key), "utm_") {
+				utm[key] = values
+			}
+		}
+		return mergeQuery(destination, utm)
+	default:
+		return destination
+	}
+}
+
+// mergeQuery adds params to the query of destination. The existing query is
+// kept byte for byte, missing parameters are appended in key order and the
+// rest of the url, fragment included, is left untouched.
+func mergeQuery(destination string, params url.Values) string {
+	if len(params) == 0 {
+		return destination
+	}
+
+	parsed, err := url.Parse(destination)
+	if err != nil {
+		return destination
+	}
+
+	present := queryKeys(parsed.RawQuery)
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		if !present[key] {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return destination
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range params[key] {
+			pairs = append(pairs, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+	if parsed.RawQuery != "" {
+		parsed.RawQuery += "&"
+	}
+	parsed.RawQuery += strings.Join(pairs, "&")
+	parsed.ForceQuery = false
+
+	return parsed.String()
+}
+
+// queryKeys returns the keys of a raw query, accepting both & and ; as
+// separators and keys without a value.
+func queryKeys(raw string) map[string]bool {
+	keys := make(map[string]bool)
+	for _, part := range strings.FieldsFunc(raw, func(r rune) bool { return r == '&' || r == ';' }) {
+		key, _, _ := strings.Cut(part, "=")
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+		keys[key] = true
+	}
+	return keys
+}
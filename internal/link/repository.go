@@ -1,8 +1,14 @@
 package link
 
 import (
+	"demo/go-server/internal/tag"
 	"demo/go-server/pkg/db"
+	"errors"
+	"slices"
+	"strings"
+	"time"
 
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
@@ -12,35 +18,258 @@ type LinkRepositoryDeps struct {
 
 type LinkRepository struct {
 	DataBase *db.Db
+	// Cache, when set, serves GetByHash and is invalidated by every
+	// write that changes what a redirect resolves to.
+	Cache LinkCache
 }
 
+// LinkFilter narrows GetAll and Count down to one user's links and,
+// optionally, to a tag, a campaign, a folder, a creation date range, a
+// destination domain, a health state or a text match on url and title.
+// Deleted switches from live links to the trash.
+//
+// GetAll pages by keyset: links are ordered by Sort, then id, and start
+// right after the After cursor.
+type LinkFilter struct {
+	UserID      uint
+	Deleted     bool
+	Tag         string
+	Campaign    string
+	FolderID    *uint
+	Query       string
+	Domain      string
+	Health      string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Sort        string
+	Desc        bool
+	After       *LinkCursor
+	Limit       int
+}
+
+func (filter LinkFilter) scope(db *gorm.DB) *gorm.DB {
+	db = db.Where("links.user_id = ?", filter.UserID)
+	if filter.Deleted {
+		db = db.Unscoped().Where("links.deleted_at is not null")
+	}
+
+	if filter.Tag != "" {
+		db = db.Where(
+			"exists (select 1 from link_tags join tags on tags.id = link_tags.tag_id where link_tags.link_id = links.id and tags.name = ?)",
+			filter.Tag,
+		)
+	}
+	if filter.Campaign != "" {
+		db = db.Where("links.utm_campaign = ?", filter.Campaign)
+	}
+	if filter.FolderID != nil {
+		db = db.Where("links.folder_id = ?", *filter.FolderID)
+	}
+	if filter.Query != "" {
+		pattern := "%" + likeEscaper.Replace(filter.Query) + "%"
+		db = db.Where("(links.url ilike ? or links.title ilike ?)", pattern, pattern)
+	}
+	if filter.Domain != "" {
+		domain := strings.ToLower(filter.Domain)
+		db = db.Where(
+			"("+urlHostSql+" = ? or "+urlHostSql+" like ?)",
+			domain, "%."+likeEscaper.Replace(domain),
+		)
+	}
+	switch filter.Health {
+	case HealthBroken:
+		db = db.Where("exists (select 1 from link_healths where link_healths.link_id = links.id and link_healths.broken)")
+	case HealthHealthy:
+		db = db.Where("exists (select 1 from link_healths where link_healths.link_id = links.id and not link_healths.broken)")
+	case HealthUnchecked:
+		db = db.Where("not exists (select 1 from link_healths where link_healths.link_id = links.id)")
+	}
+	if filter.CreatedFrom != nil {
+		db = db.Where("links.created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		db = db.Where("links.created_at < ?", *filter.CreatedTo)
+	}
+
+	return db
+}
+
+// urlHostSql extracts the lowercased host of a link's destination.
+const urlHostSql = `lower(substring(links.url from '^[A-Za-z][A-Za-z0-9+.-]*://(?:[^@/]*@)?([^/:?#]+)'))`
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 func NewLinkRepository(database *db.Db) *LinkRepository {
 	return &LinkRepository{
 		DataBase: database,
 	}
 }
 
+// Create stores link. Tags that only carry a name are looked up or
+// created in the same transaction.
 func (repo *LinkRepository) Create(link *Link) (*Link, error) {
-	result := repo.DataBase.DB.Create(link)
+	err := repo.DataBase.DB.Transaction(func(tx *gorm.DB) error {
+		if err := resolveTags(tx, link); err != nil {
+			return err
+		}
+		return tx.Create(link).Error
+	})
 
-	if result.Error != nil {
-		return nil, result.Error
+	if err != nil {
+		return nil, err
 	}
+	repo.forgetHash(link.DomainID, link.Hash)
 
 	return link, nil
 }
 
-func (repo *LinkRepository) GetByHash(hash string) (*Link, error) {
+// CreateMany inserts all links, and the tags they name, in a single
+// transaction, so either every link is stored or none is.
+func (repo *LinkRepository) CreateMany(links []*Link) error {
+	err := repo.DataBase.DB.Transaction(func(tx *gorm.DB) error {
+		if err := resolveTags(tx, links...); err != nil {
+			return err
+		}
+		return tx.CreateInBatches(links, 100).Error
+	})
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		repo.forgetHash(link.DomainID, link.Hash)
+	}
+
+	return nil
+}
+
+// resolveTags swaps tags without an id for the owner's stored tags of
+// that name, creating the missing ones on tx. Each name is looked up once
+// per owner, however many links carry it.
+func resolveTags(tx *gorm.DB, links ...*Link) error {
+	names := map[uint][]string{}
+	seen := map[uint]map[string]struct{}{}
+	for _, link := range links {
+		for _, t := range link.Tags {
+			if t.ID != 0 {
+				continue
+			}
+			if seen[link.UserID] == nil {
+				seen[link.UserID] = map[string]struct{}{}
+			}
+			if _, ok := seen[link.UserID][t.Name]; !ok {
+				seen[link.UserID][t.Name] = struct{}{}
+				names[link.UserID] = append(names[link.UserID], t.Name)
+			}
+		}
+	}
+
+	for userID, userNames := range names {
+		tags, err := tag.FindOrCreate(tx, userID, userNames)
+		if err != nil {
+			return err
+		}
+		byName := make(map[string]tag.Tag, len(tags))
+		for _, t := range tags {
+			byName[t.Name] = t
+		}
+		for _, link := range links {
+			if link.UserID != userID {
+				continue
+			}
+			resolved := make([]tag.Tag, 0, len(link.Tags))
+			ids := map[uint]struct{}{}
+			for _, t := range link.Tags {
+				if t.ID == 0 {
+					t = byName[t.Name]
+				}
+				if _, ok := ids[t.ID]; !ok {
+					ids[t.ID] = struct{}{}
+					resolved = append(resolved, t)
+				}
+			}
+			link.Tags = resolved
+		}
+	}
+
+	return nil
+}
+
+// NextID reserves the next value of the links id sequence.
+func (repo *LinkRepository) NextID() (uint, error) {
+	var id uint
+	result := repo.DataBase.DB.Raw("select nextval(pg_get_serial_sequence('links', 'id'))").Scan(&id)
+
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return id, nil
+}
+
+// GetByHash finds the link served as hash on a custom domain, or on the
+// default hosts when domainID is nil.
+// Results, including misses, are cached when the repository has a Cache,
+// unless the cache was invalidated during the read; callers get their own
+// copy of the link.
+func (repo *LinkRepository) GetByHash(domainID *uint, hash string) (*Link, error) {
+	key := hashKey(domainID, hash)
+	var generation uint64
+	if repo.Cache != nil {
+		if cached, ok := repo.Cache.Get(key); ok {
+			if cached == nil {
+				return nil, gorm.ErrRecordNotFound
+			}
+			link := *cached
+			return &link, nil
+		}
+		generation = repo.Cache.Generation()
+	}
+
 	var link Link
-	result := repo.DataBase.DB.First(&link, "hash = ?", hash)
+	result := repo.DataBase.DB.
+		Scopes(onDomain(domainID)).
+		Preload("Rules", orderByPosition).
+		Preload("Variants").
+		First(&link, "hash = ?", hash)
 
 	if result.Error != nil {
+		if repo.Cache != nil && errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			repo.Cache.Set(key, nil, generation)
+		}
 		return nil, result.Error
 	}
 
+	if repo.Cache != nil {
+		cached := link
+		repo.Cache.Set(key, &cached, generation)
+	}
+
 	return &link, nil
 }
 
+// HashExists reports whether hash is used on the domain by any link,
+// including soft-deleted ones that still hold the unique index.
+func (repo *LinkRepository) HashExists(domainID *uint, hash string) bool {
+	var count int64
+	repo.DataBase.DB.
+		Unscoped().
+		Model(&Link{}).
+		Scopes(onDomain(domainID)).
+		Where("hash = ?", hash).
+		Count(&count)
+
+	return count > 0
+}
+
+func onDomain(domainID *uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if domainID == nil {
+			return db.Where("domain_id is null")
+		}
+		return db.Where("domain_id = ?", *domainID)
+	}
+}
+
 func (repo *LinkRepository) GetById(id uint) (*Link, error) {
 	var link Link
 	result := repo.DataBase.DB.First(&link, id)
@@ -52,45 +281,517 @@ func (repo *LinkRepository) GetById(id uint) (*Link, error) {
 	return &link, nil
 }
 
+// linkUpdateColumns are the columns Update writes. They are written even
+// when zero, so an edit can clear them.
+var linkUpdateColumns = []string{
+	"url", "hash", "title", "description", "folder_id",
+	"redirect_code", "redirect_mode", "forward_query",
+	"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content",
+	"ios_deep_link", "android_deep_link", "ios_store_url", "android_store_url",
+}
+
 func (repo *LinkRepository) Update(link *Link) (*Link, error) {
-	result := repo.DataBase.DB.Clauses(clause.Returning{}).Updates(link)
+	result := repo.DataBase.DB.
+		Clauses(clause.Returning{}).
+		Select(linkUpdateColumns).
+		Where("user_id = ?", link.UserID).
+		Updates(link)
 
 	if result.Error != nil {
 		return nil, result.Error
 	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	repo.forget(link.ID)
+	repo.forgetHash(link.DomainID, link.Hash)
 
 	return link, nil
 }
 
-func (repo *LinkRepository) Delete(id uint) error {
-	result := repo.DataBase.DB.Delete(&Link{}, id)
+// UpdateStatus writes active and starts_at as they are, including false
+// and nil, which Update would skip.
+func (repo *LinkRepository) UpdateStatus(link *Link) error {
+	err := repo.DataBase.DB.
+		Model(link).
+		Select("active", "starts_at").
+		Updates(link).Error
+	if err == nil {
+		repo.forget(link.ID)
+	}
+
+	return err
+}
+
+// UpdatePreview stores the fetched preview, keeping a title or
+// description the user has typed in by hand.
+func (repo *LinkRepository) UpdatePreview(link *Link, preview *Preview) error {
+	return repo.DataBase.DB.
+		Model(&Link{}).
+		Where("id = ?", link.ID).
+		Updates(map[string]any{
+			"title":       gorm.Expr("case when title = '' then ? else title end", preview.Title),
+			"description": gorm.Expr("case when description = '' then ? else description end", preview.Description),
+			"image":       preview.Image,
+			"favicon":     preview.Favicon,
+		}).Error
+}
+
+// ConsumeClick atomically counts a visit against the link's click budget
+// and reports false once the budget is exhausted.
+func (repo *LinkRepository) ConsumeClick(id uint) bool {
+	result := repo.DataBase.DB.
+		Model(&Link{}).
+		Where("id = ? and (max_clicks is null or clicks < max_clicks)", id).
+		UpdateColumn("clicks", gorm.Expr("clicks + 1"))
+
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+func (repo *LinkRepository) Delete(id, userID uint) error {
+	result := repo.DataBase.DB.
+		Where("user_id = ?", userID).
+		Delete(&Link{}, id)
 
 	if result.Error != nil {
 		return result.Error
 	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	repo.forget(id)
 
 	return nil
 }
 
-func (repo *LinkRepository) GetAll(limit, offset int) []Link {
+// GetDeleted returns a link from the trash.
+func (repo *LinkRepository) GetDeleted(id uint) (*Link, error) {
+	var link Link
+	result := repo.DataBase.DB.
+		Unscoped().
+		Where("deleted_at is not null").
+		First(&link, id)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &link, nil
+}
+
+// Restore takes a link out of the trash.
+func (repo *LinkRepository) Restore(link *Link) error {
+	err := repo.DataBase.DB.
+		Unscoped().
+		Model(link).
+		Update("deleted_at", nil).Error
+	if err == nil {
+		repo.forgetHash(link.DomainID, link.Hash)
+	}
+
+	return err
+}
+
+// Purge removes a link for good, together with its rules, variants,
+// tags and revisions. Its hash becomes free again.
+func (repo *LinkRepository) Purge(id uint) error {
+	err := repo.DataBase.DB.
+		Unscoped().
+		Delete(&Link{}, id).Error
+	if err == nil {
+		repo.forget(id)
+	}
+
+	return err
+}
+
+// PurgeDeletedBefore purges every link that went to the trash before t.
+func (repo *LinkRepository) PurgeDeletedBefore(t time.Time) (int64, error) {
+	result := repo.DataBase.DB.
+		Unscoped().
+		Where("deleted_at is not null and deleted_at < ?", t).
+		Delete(&Link{})
+
+	return result.RowsAffected, result.Error
+}
+
+// DeleteMany soft-deletes the links among ids that belong to userID and
+// returns the ids that were actually deleted.
+func (repo *LinkRepository) DeleteMany(ids []uint, userID uint) ([]uint, error) {
+	var deleted []uint
+	err := repo.DataBase.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Link{}).
+			Where("id in ? and user_id = ?", ids, userID).
+			Pluck("id", &deleted).Error
+		if err != nil || len(deleted) == 0 {
+			return err
+		}
+		return tx.Delete(&Link{}, deleted).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	repo.forget(deleted...)
+
+	return deleted, nil
+}
+
+// GetAll returns a page of links. A cursor whose value does not fit its
+// sort yields errCursorValue.
+func (repo *LinkRepository) GetAll(filter LinkFilter) ([]Link, error) {
+	column, ok := linkSortColumns[filter.Sort]
+	if !ok {
+		column = linkSortColumns[SortCreatedAt]
+	}
+	direction, compare := "asc", ">"
+	if filter.Desc {
+		direction, compare = "desc", "<"
+	}
+
 	var links []Link
-	repo.DataBase.DB.
-		Table("links").
-		Where("deleted_at is null").
-		Order("id asc").
-		Limit(limit).
-		Offset(offset).
-		Scan(&links)
+	query := repo.DataBase.DB.
+		Model(&Link{}).
+		Select("links.*, " + clickTotalSql + " as total_clicks").
+		Scopes(filter.scope).
+		Preload("Tags").
+		Order(column + " " + direction).
+		Order("links.id " + direction)
 
-	return links
+	if filter.After != nil {
+		value, err := filter.After.value()
+		if err != nil {
+			return nil, errCursorValue
+		}
+		query = query.Where("("+column+", links.id) "+compare+" (?, ?)", value, filter.After.ID)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if err := query.Find(&links).Error; err != nil {
+		return nil, err
+	}
+
+	return links, nil
 }
 
-func (repo *LinkRepository) Count() int64 {
+func (repo *LinkRepository) Count(filter LinkFilter) int64 {
 	var count int64
 	repo.DataBase.DB.
-		Table("links").
-		Where("deleted_at is null").
+		Model(&Link{}).
+		Scopes(filter.scope).
 		Count(&count)
 
 	return count
 }
+
+// ReplaceRules swaps the routing rules of the link for rules.
+func (repo *LinkRepository) ReplaceRules(link *Link, rules []RoutingRule) error {
+	err := repo.DataBase.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Where("link_id = ?", link.ID).Delete(&RoutingRule{}).Error
+		if err != nil || len(rules) == 0 {
+			return err
+		}
+		for i := range rules {
+			rules[i].LinkID = link.ID
+		}
+		return tx.Create(&rules).Error
+	})
+	if err == nil {
+		repo.forget(link.ID)
+	}
+
+	return err
+}
+
+// ReplaceVariants swaps the A/B variants of the link for variants.
+func (repo *LinkRepository) ReplaceVariants(link *Link, variants []Variant) error {
+	err := repo.DataBase.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Where("link_id = ?", link.ID).Delete(&Variant{}).Error
+		if err != nil || len(variants) == 0 {
+			return err
+		}
+		for i := range variants {
+			variants[i].LinkID = link.ID
+		}
+		return tx.Create(&variants).Error
+	})
+	if err == nil {
+		repo.forget(link.ID)
+	}
+
+	return err
+}
+
+func orderByPosition(db *gorm.DB) *gorm.DB {
+	return db.Order("position asc")
+}
+
+// ReplaceTags sets the tags of the link to exactly tags.
+func (repo *LinkRepository) ReplaceTags(link *Link, tags []tag.Tag) error {
+	return repo.DataBase.DB.Model(link).Association("Tags").Replace(tags)
+}
+
+// Overwrite replaces the snapshot columns, expiry settings, rules, variants
+// and tags of the stored link link.ID with those of link. The password is
+// only replaced when link has one, so a row without it keeps the link
+// protected.
+func (repo *LinkRepository) Overwrite(link *Link) error {
+	columns := *link
+	columns.Rules, columns.Variants, columns.Tags = nil, nil, nil
+	selected := slices.DeleteFunc(slices.Clone(snapshotColumns), func(column string) bool {
+		return column == "password" && link.Password == ""
+	})
+	err := repo.DataBase.DB.
+		Model(&columns).
+		Select(selected).
+		Updates(&columns).Error
+	if err != nil {
+		return err
+	}
+	repo.forgetHash(link.DomainID, link.Hash)
+
+	if err := repo.ReplaceRules(link, link.Rules); err != nil {
+		return err
+	}
+	if err := repo.ReplaceVariants(link, link.Variants); err != nil {
+		return err
+	}
+	return repo.ReplaceTags(link, link.Tags)
+}
+
+// ExportBatches walks the live links of userID in id order, size at a
+// time, with their tags, rules, variants, folder and domain loaded.
+func (repo *LinkRepository) ExportBatches(userID uint, size int, fn func(links []Link) error) error {
+	var links []Link
+	return repo.DataBase.DB.
+		Preload("Tags").
+		Preload("Rules", orderByPosition).
+		Preload("Variants").
+		Preload("Folder").
+		Preload("Domain").
+		Where("user_id = ?", userID).
+		FindInBatches(&links, size, func(tx *gorm.DB, batch int) error {
+			return fn(links)
+		}).Error
+}
+
+// ClickTotals sums the recorded clicks of each of the links in ids.
+func (repo *LinkRepository) ClickTotals(ids []uint) map[uint]int64 {
+	var rows []struct {
+		LinkID uint
+		Clicks int64
+	}
+	repo.DataBase.DB.
+		Table("stats").
+		Select("link_id, sum(clicks) as clicks").
+		Where("link_id in ? and deleted_at is null", ids).
+		Group("link_id").
+		Scan(&rows)
+
+	totals := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		totals[row.LinkID] = row.Clicks
+	}
+	return totals
+}
+
+// Transaction runs fn with a repository bound to a single transaction.
+// Cache invalidations made by fn take effect once it has committed.
+func (repo *LinkRepository) Transaction(fn func(tx *LinkRepository) error) error {
+	var deferred *deferredCache
+	if repo.Cache != nil {
+		deferred = &deferredCache{LinkCache: repo.Cache}
+	}
+
+	err := repo.DataBase.DB.Transaction(func(tx *gorm.DB) error {
+		txRepo := NewLinkRepository(&db.Db{DB: tx})
+		if deferred != nil {
+			txRepo.Cache = deferred
+		}
+		return fn(txRepo)
+	})
+	if err == nil && deferred != nil {
+		deferred.flush()
+	}
+
+	return err
+}
+
+// SaveSnapshot writes every snapshot column of link, including zero
+// values. Rules, variants and tags are replaced separately.
+func (repo *LinkRepository) SaveSnapshot(link *Link) error {
+	err := repo.DataBase.DB.
+		Model(link).
+		Select(snapshotColumns).
+		Updates(link).Error
+	if err == nil {
+		repo.forget(link.ID)
+		repo.forgetHash(link.DomainID, link.Hash)
+	}
+
+	return err
+}
+
+// AddRevision stores rev as the next version of its link. The link row
+// is locked so concurrent edits get consecutive versions.
+func (repo *LinkRepository) AddRevision(rev *LinkRevision) error {
+	return repo.DataBase.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			First(&Link{}, rev.LinkID).Error
+		if err != nil {
+			return err
+		}
+
+		var last uint
+		err = tx.Model(&LinkRevision{}).
+			Where("link_id = ?", rev.LinkID).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&last).Error
+		if err != nil {
+			return err
+		}
+
+		rev.Version = last + 1
+		return tx.Create(rev).Error
+	})
+}
+
+// GetRevisions returns the history of a link, newest first.
+func (repo *LinkRepository) GetRevisions(linkID uint) []LinkRevision {
+	var revisions []LinkRevision
+	repo.DataBase.DB.
+		Where("link_id = ?", linkID).
+		Order("version desc").
+		Find(&revisions)
+
+	return revisions
+}
+
+func (repo *LinkRepository) GetRevision(linkID, version uint) (*LinkRevision, error) {
+	var revision LinkRevision
+	result := repo.DataBase.DB.
+		Where("link_id = ? AND version = ?", linkID, version).
+		First(&revision)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &revision, nil
+}
+
+// GetWithRelations returns a link with its tags, rules and variants.
+func (repo *LinkRepository) GetWithRelations(id uint) (*Link, error) {
+	var link Link
+	result := repo.DataBase.DB.
+		Preload("Tags").
+		Preload("Rules", orderByPosition).
+		Preload("Variants").
+		First(&link, id)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &link, nil
+}
+
+func (repo *LinkRepository) CreateTemplate(template *LinkTemplate) (*LinkTemplate, error) {
+	result := repo.DataBase.DB.Create(template)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return template, nil
+}
+
+func (repo *LinkRepository) GetTemplates(userID uint) []LinkTemplate {
+	var templates []LinkTemplate
+	repo.DataBase.DB.
+		Where("user_id = ?", userID).
+		Order("id asc").
+		Find(&templates)
+
+	return templates
+}
+
+func (repo *LinkRepository) GetTemplate(id, userID uint) (*LinkTemplate, error) {
+	var template LinkTemplate
+	result := repo.DataBase.DB.
+		Where("user_id = ?", userID).
+		First(&template, id)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &template, nil
+}
+
+func (repo *LinkRepository) DeleteTemplate(id, userID uint) error {
+	result := repo.DataBase.DB.
+		Unscoped().
+		Where("user_id = ?", userID).
+		Delete(&LinkTemplate{}, id)
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetActiveAfter returns up to limit live links with an id above afterID,
+// in id order, for workers that walk over all links.
+func (repo *LinkRepository) GetActiveAfter(afterID uint, limit int) []Link {
+	var links []Link
+	repo.DataBase.DB.
+		Select("id", "url").
+		Where("active and id > ?", afterID).
+		Order("id asc").
+		Limit(limit).
+		Find(&links)
+
+	return links
+}
+
+// SaveHealth stores the latest check of a link, counting consecutive
+// broken checks in Failures.
+func (repo *LinkRepository) SaveHealth(health *LinkHealth) error {
+	failures := clause.Expr{SQL: "0"}
+	if health.Broken {
+		failures = clause.Expr{SQL: "link_healths.failures + 1"}
+		health.Failures = 1
+	}
+
+	return repo.DataBase.DB.
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "link_id"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"status_code": health.StatusCode,
+				"latency_ms":  health.LatencyMs,
+				"error":       health.Error,
+				"broken":      health.Broken,
+				"failures":    failures,
+				"checked_at":  health.CheckedAt,
+			}),
+		}).
+		Create(health).Error
+}
+
+func (repo *LinkRepository) GetHealth(linkID uint) (*LinkHealth, error) {
+	var health LinkHealth
+	result := repo.DataBase.DB.First(&health, "link_id = ?", linkID)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &health, nil
+}
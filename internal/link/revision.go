@@ -0,0 +1,197 @@
+package link
+
+import (
+	"database/sql/driver"
+	"demo/go-server/internal/user"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// LinkSnapshot holds the editable fields of a link. The json names are
+// the column names, so a snapshot can be written back as it is; the
+// fields marked gorm:"-" live in other tables.
+type LinkSnapshot struct {
+	Url             string               `json:"url"`
+	Hash            string               `json:"hash"`
+	Title           string               `json:"title"`
+	Description     string               `json:"description"`
+	FolderID        *uint                `json:"folder_id"`
+	Active          bool                 `json:"active"`
+	StartsAt        *time.Time           `json:"starts_at"`
+	RedirectCode    int                  `json:"redirect_code"`
+	RedirectMode    string               `json:"redirect_mode"`
+	ForwardQuery    string               `json:"forward_query"`
+	UtmSource       string               `json:"utm_source"`
+	UtmMedium       string               `json:"utm_medium"`
+	UtmCampaign     string               `json:"utm_campaign"`
+	UtmTerm         string               `json:"utm_term"`
+	UtmContent      string               `json:"utm_content"`
+	IosDeepLink     string               `json:"ios_deep_link"`
+	AndroidDeepLink string               `json:"android_deep_link"`
+	IosStoreUrl     string               `json:"ios_store_url"`
+	AndroidStoreUrl string               `json:"android_store_url"`
+	ExpiresAt       *time.Time           `json:"expires_at"`
+	MaxClicks       *uint                `json:"max_clicks"`
+	Password        string               `json:"password"`
+	Rules           []RoutingRuleRequest `json:"rules" gorm:"-"`
+	Variants        []VariantRequest     `json:"variants" gorm:"-"`
+	Tags            []string             `json:"tags" gorm:"-"`
+}
+
+// snapshotColumns lists the snapshot fields stored on the link row, in
+// field order.
+var snapshotColumns = func() []string {
+	snapshotType := reflect.TypeOf(LinkSnapshot{})
+	var columns []string
+	for i := range snapshotType.NumField() {
+		field := snapshotType.Field(i)
+		if field.Tag.Get("gorm") != "-" {
+			columns = append(columns, field.Tag.Get("json"))
+		}
+	}
+	return columns
+}()
+
+// snapshotOf expects the rules, variants and tags of link to be loaded.
+func snapshotOf(link *Link) LinkSnapshot {
+	snapshot := LinkSnapshot{
+		Url:             link.Url,
+		Hash:            link.Hash,
+		Title:           link.Title,
+		Description:     link.Description,
+		FolderID:        link.FolderID,
+		Active:          link.Active,
+		RedirectCode:    link.RedirectCode,
+		RedirectMode:    link.RedirectMode,
+		ForwardQuery:    link.ForwardQuery,
+		UtmSource:       link.UtmSource,
+		UtmMedium:       link.UtmMedium,
+		UtmCampaign:     link.UtmCampaign,
+		UtmTerm:         link.UtmTerm,
+		UtmContent:      link.UtmContent,
+		IosDeepLink:     link.IosDeepLink,
+		AndroidDeepLink: link.AndroidDeepLink,
+		IosStoreUrl:     link.IosStoreUrl,
+		AndroidStoreUrl: link.AndroidStoreUrl,
+		StartsAt:        utcTime(link.StartsAt),
+		ExpiresAt:       utcTime(link.ExpiresAt),
+		MaxClicks:       link.MaxClicks,
+		Password:        link.Password,
+		Rules:           ruleRequests(link.Rules),
+		Variants:        variantRequests(link.Variants),
+		Tags:            tagNames(link.Tags),
+	}
+	// Variants and tags come back from the database in no fixed order.
+	slices.SortFunc(snapshot.Variants, func(a, b VariantRequest) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	slices.Sort(snapshot.Tags)
+	return snapshot
+}
+
+// utcTime returns t in UTC. The database hands times back in its own
+// zone; snapshots compare instants.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// applyTo copies the columns of the snapshot onto link. Rules, variants
+// and tags are left to the caller.
+func (snapshot LinkSnapshot) applyTo(link *Link) {
+	link.Url = snapshot.Url
+	link.Hash = snapshot.Hash
+	link.Title = snapshot.Title
+	link.Description = snapshot.Description
+	link.FolderID = snapshot.FolderID
+	link.Active = snapshot.Active
+	link.StartsAt = snapshot.StartsAt
+	link.RedirectCode = snapshot.RedirectCode
+	link.RedirectMode = snapshot.RedirectMode
+	link.ForwardQuery = snapshot.ForwardQuery
+	link.UtmSource = snapshot.UtmSource
+	link.UtmMedium = snapshot.UtmMedium
+	link.UtmCampaign = snapshot.UtmCampaign
+	link.UtmTerm = snapshot.UtmTerm
+	link.UtmContent = snapshot.UtmContent
+	link.IosDeepLink = snapshot.IosDeepLink
+	link.AndroidDeepLink = snapshot.AndroidDeepLink
+	link.IosStoreUrl = snapshot.IosStoreUrl
+	link.AndroidStoreUrl = snapshot.AndroidStoreUrl
+	link.ExpiresAt = snapshot.ExpiresAt
+	link.MaxClicks = snapshot.MaxClicks
+	link.Password = snapshot.Password
+}
+
+// changedFields returns the fields whose values differ in other.
+func (snapshot LinkSnapshot) changedFields(other LinkSnapshot) FieldList {
+	before := reflect.ValueOf(snapshot)
+	after := reflect.ValueOf(other)
+	fields := FieldList{}
+	for i := range before.NumField() {
+		field := before.Type().Field(i)
+		if !reflect.DeepEqual(before.Field(i).Interface(), after.Field(i).Interface()) {
+			fields = append(fields, field.Tag.Get("json"))
+		}
+	}
+	return fields
+}
+
+// recordRevision stores the change from before to after made by author.
+// Nothing is stored when no snapshot field changed. Both links need their
+// rules, variants and tags loaded.
+func recordRevision(repo *LinkRepository, author *user.User, before, after *Link, reason string) error {
+	old, current := snapshotOf(before), snapshotOf(after)
+	fields := old.changedFields(current)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return repo.AddRevision(&LinkRevision{
+		LinkID: after.ID,
+		UserID: author.ID,
+		Author: author.Email,
+		Reason: strings.TrimSpace(reason),
+		Fields: fields,
+		Old:    old,
+		New:    current,
+	})
+}
+
+func (snapshot LinkSnapshot) Value() (driver.Value, error) {
+	return json.Marshal(snapshot)
+}
+
+func (snapshot *LinkSnapshot) Scan(value any) error {
+	return scanJson(value, snapshot)
+}
+
+// FieldList is stored as a json array of column names.
+type FieldList []string
+
+func (fields FieldList) Value() (driver.Value, error) {
+	return json.Marshal(fields)
+}
+
+func (fields *FieldList) Scan(value any) error {
+	return scanJson(value, fields)
+}
+
+func scanJson(value any, target any) error {
+	switch data := value.(type) {
+	case []byte:
+		return json.Unmarshal(data, target)
+	case string:
+		return json.Unmarshal([]byte(data), target)
+	case nil:
+		return nil
+	}
+	return errors.New("unsupported json column value")
+}
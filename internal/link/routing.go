@@ -0,0 +1,101 @@
+package link
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Visitor is what routing rules can match a visit on.
+type Visitor struct {
+	Platform  string
+	Languages []string
+	Country   string
+	Time      time.Time
+}
+
+// Matches reports whether every condition set on the rule holds for the
+// visitor. A rule without conditions matches everybody.
+func (rule *RoutingRule) Matches(visitor *Visitor) bool {
+	if rule.Platform != "" && rule.Platform != visitor.Platform {
+		return false
+	}
+	if rule.Language != "" && !slices.Contains(visitor.Languages, strings.ToLower(rule.Language)) {
+		return false
+	}
+	if rule.Country != "" && !strings.EqualFold(rule.Country, visitor.Country) {
+		return false
+	}
+	if rule.FromHour != nil && rule.ToHour != nil {
+		return inHours(rule.localTime(visitor.Time).Hour(), *rule.FromHour, *rule.ToHour)
+	}
+	return true
+}
+
+func (rule *RoutingRule) localTime(t time.Time) time.Time {
+	if rule.Timezone == "" {
+		return t.UTC()
+	}
+	location, err := loadLocation(rule.Timezone)
+	if err != nil {
+		return t.UTC()
+	}
+	return t.In(location)
+}
+
+type cachedLocation struct {
+	location *time.Location
+	err      error
+}
+
+// locations caches time zones by name, failures included, since
+// time.LoadLocation reads the zoneinfo database on every call.
+var locations sync.Map
+
+// loadLocation is time.LoadLocation, once per zone.
+func loadLocation(zone string) (*time.Location, error) {
+	if cached, ok := locations.Load(zone); ok {
+		entry := cached.(cachedLocation)
+		return entry.location, entry.err
+	}
+	location, err := time.LoadLocation(zone)
+	cached, _ := locations.LoadOrStore(zone, cachedLocation{location: location, err: err})
+	entry := cached.(cachedLocation)
+	return entry.location, entry.err
+}
+
+// inHours checks hour against the window [from, to), which wraps around
+// midnight when from is after to. Equal bounds cover the whole day.
+func inHours(hour, from, to int) bool {
+	switch {
+	case from == to:
+		return true
+	case from < to:
+		return hour >= from && hour < to
+	default:
+		return hour >= from || hour < to
+	}
+}
+
+// NeedsCountry reports whether any rule looks at the country, so the
+// GeoIP lookup can be skipped otherwise.
+func (link *Link) NeedsCountry() bool {
+	for _, rule := range link.Rules {
+		if rule.Country != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchRule returns the first rule, in position order, that matches the
+// visitor.
+func (link *Link) MatchRule(visitor *Visitor) *RoutingRule {
+	for i := range link.Rules {
+		if link.Rules[i].Matches(visitor) {
+			return &link.Rules[i]
+		}
+	}
+	return nil
+}
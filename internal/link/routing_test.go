@@ -0,0 +1,68 @@
+package link_test
+
+import (
+	"demo/go-server/internal/link"
+	"demo/go-server/pkg/useragent"
+	"testing"
+	"time"
+)
+
+func TestLinkMatchRule(t *testing.T) {
+	from, to := 22, 6
+	l := &link.Link{
+		Url: "https://example.com",
+		Rules: []link.RoutingRule{
+			{Platform: useragent.PlatformIOS, Url: "https://apps.apple.com/app"},
+			{Platform: useragent.PlatformAndroid, Url: "https://play.google.com/app"},
+			{Country: "DE", Language: "de", Url: "https://example.de"},
+			{FromHour: &from, ToHour: &to, Url: "https://example.com/night"},
+		},
+	}
+	noon := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	midnight := time.Date(2026, 1, 1, 23, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		visitor  link.Visitor
+		expected string
+	}{
+		{link.Visitor{Platform: useragent.PlatformIOS, Time: midnight}, "https://apps.apple.com/app"},
+		{link.Visitor{Platform: useragent.PlatformAndroid, Time: noon}, "https://play.google.com/app"},
+		{link.Visitor{Platform: useragent.PlatformDesktop, Country: "DE", Languages: []string{"de", "en"}, Time: noon}, "https://example.de"},
+		{link.Visitor{Platform: useragent.PlatformDesktop, Country: "DE", Languages: []string{"en"}, Time: noon}, "https://example.com"},
+		{link.Visitor{Platform: useragent.PlatformDesktop, Time: midnight}, "https://example.com/night"},
+		{link.Visitor{Platform: useragent.PlatformDesktop, Time: noon}, "https://example.com"},
+	}
+
+	for _, c := range cases {
+		got := l.Url
+		if rule := l.MatchRule(&c.visitor); rule != nil {
+			got = rule.Url
+		}
+		if got != c.expected {
+			t.Errorf("%+v: got %s expected %s", c.visitor, got, c.expected)
+		}
+	}
+}
+
+func TestLinkMatchRuleTimezone(t *testing.T) {
+	from, to := 9, 17
+	rule := link.RoutingRule{FromHour: &from, ToHour: &to, Timezone: "Asia/Tokyo"}
+	unknown := link.RoutingRule{FromHour: &from, ToHour: &to, Timezone: "Nowhere/Town"}
+
+	tokyoMorning := time.Date(2026, 1, 1, 2, 0, 0, 0, time.UTC)
+	tokyoNight := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Repeat to go through the cached locations too.
+	for range 2 {
+		if !rule.Matches(&link.Visitor{Time: tokyoMorning}) {
+			t.Errorf("Expected 11:00 in Tokyo to match")
+		}
+		if rule.Matches(&link.Visitor{Time: tokyoNight}) {
+			t.Errorf("Expected 21:00 in Tokyo not to match")
+		}
+		// An unknown zone falls back to UTC.
+		if !unknown.Matches(&link.Visitor{Time: tokyoNight}) {
+			t.Errorf("Expected 12:00 UTC to match")
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package link
+
+import (
+	"context"
+	"demo/go-server/pkg/event"
+	"log"
+	"time"
+)
+
+type PreviewServiceDeps struct {
+	EventBus       *event.EventBus
+	LinkRepository *LinkRepository
+	PreviewFetcher *PreviewFetcher
+	Timeout        time.Duration
+	Workers        int
+	QueueSize      int
+}
+
+// PreviewService fills in title, description, image and favicon of newly
+// created links from their target page, outside the request that created
+// them. At most Workers previews are fetched at once and QueueSize more
+// wait for a worker; links created while the queue is full are skipped.
+type PreviewService struct {
+	EventBus       *event.EventBus
+	LinkRepository *LinkRepository
+	PreviewFetcher *PreviewFetcher
+	Timeout        time.Duration
+	Workers        int
+	QueueSize      int
+}
+
+func NewPreviewService(deps *PreviewServiceDeps) *PreviewService {
+	return &PreviewService{
+		EventBus:       deps.EventBus,
+		LinkRepository: deps.LinkRepository,
+		PreviewFetcher: deps.PreviewFetcher,
+		Timeout:        deps.Timeout,
+		Workers:        max(deps.Workers, 1),
+		QueueSize:      max(deps.QueueSize, 0),
+	}
+}
+
+func (s *PreviewService) FetchPreviews() {
+	queue := make(chan uint, s.QueueSize)
+	for range s.Workers {
+		go func() {
+			for id := range queue {
+				s.FetchPreview(id)
+			}
+		}()
+	}
+
+	for msg := range s.EventBus.Subscribe() {
+		switch msg.Type {
+		case event.LinkCreated:
+			id, ok := msg.Data.(uint)
+			if !ok {
+				log.Println("Bad EventLinkCreated Data: ", msg.Data)
+				continue
+			}
+			select {
+			case queue <- id:
+			default:
+				log.Println("Preview queue is full, skipping link", id)
+			}
+		}
+	}
+	close(queue)
+}
+
+func (s *PreviewService) FetchPreview(id uint) {
+	link, err := s.LinkRepository.GetById(id)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+
+	preview, err := s.PreviewFetcher.Fetch(ctx, link.Url)
+	if err != nil {
+		log.Println("Preview for link", id, "failed:", err)
+		return
+	}
+
+	if err := s.LinkRepository.UpdatePreview(link, preview); err != nil {
+		log.Println("Preview for link", id, "not saved:", err)
+	}
+}
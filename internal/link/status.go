@@ -0,0 +1,44 @@
+package link
+
+import (
+	"html/template"
+	"net/http"
+)
+
+const (
+	InactiveModeNotFound = "not_found"
+	InactiveModePage     = "page"
+	InactiveModeRedirect = "redirect"
+)
+
+var inactiveTemplate = template.Must(template.New("inactive").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Link not available</title></head>
+<body>
+<p>{{.}}</p>
+</body>
+</html>
+`))
+
+// writeInactive answers a visit to a disabled or not yet active link the
+// way the config asks for: a plain 404, a landing page or a redirect to a
+// fallback url. The landing page is served as a 404 too so crawlers and
+// link checkers don't take it for the destination.
+func (handler *LinkHandler) writeInactive(w http.ResponseWriter, req *http.Request) {
+	conf := handler.Config.Link
+
+	switch conf.InactiveMode {
+	case InactiveModePage:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusNotFound)
+		_ = inactiveTemplate.Execute(w, conf.InactiveMessage)
+	case InactiveModeRedirect:
+		if conf.InactiveUrl != "" {
+			http.Redirect(w, req, conf.InactiveUrl, http.StatusFound)
+			return
+		}
+		fallthrough
+	default:
+		http.Error(w, ErrLinkInactive, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,264 @@
+package link
+
+import (
+	"demo/go-server/pkg/request"
+	"demo/go-server/pkg/response"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+func (handler *LinkHandler) CreateTemplate() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, ok := handler.currentUserID(w, req)
+		if !ok {
+			return
+		}
+
+		body, err := request.HandleBody[LinkTemplateRequest](&w, req)
+		if err != nil {
+			return
+		}
+
+		if body.Link.Password != "" {
+			http.Error(w, ErrTemplatePassword, http.StatusUnprocessableEntity)
+			return
+		}
+
+		// Fill every placeholder with a plain sample value so the rest
+		// of the request can be validated as a normal link.
+		variables := templateVariables(&body.Link)
+		sample := make(map[string]string, len(variables))
+		for _, name := range variables {
+			sample[name] = "x"
+		}
+		rendered, err := renderTemplate(&body.Link, sample)
+		if err == nil {
+			err = request.IsValid(*rendered)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		template, err := handler.LinkRepository.CreateTemplate(&LinkTemplate{
+			UserID: userID,
+			Name:   body.Name,
+			Link:   body.Link,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		template.Variables = variables
+
+		response.WriteResponse(w, template, 201)
+	}
+}
+
+func (handler *LinkHandler) GetTemplates() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, ok := handler.currentUserID(w, req)
+		if !ok {
+			return
+		}
+
+		templates := handler.LinkRepository.GetTemplates(userID)
+		for i := range templates {
+			templates[i].Variables = templateVariables(&templates[i].Link)
+		}
+
+		response.WriteResponse(w, templates, 200)
+	}
+}
+
+func (handler *LinkHandler) DeleteTemplate() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, ok := handler.currentUserID(w, req)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.ParseUint(req.PathValue("id"), 10, 32)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = handler.LinkRepository.DeleteTemplate(uint(id), userID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, ErrTemplateNotFound, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response.WriteResponse(w, nil, 200)
+	}
+}
+
+// Generate creates one link per row of values, either a JSON body with
+// "rows" or a CSV file whose header names the variables. Like bulk
+// create, nothing is stored unless every row makes a valid link.
+func (handler *LinkHandler) Generate() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, ok := handler.currentUserID(w, req)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.ParseUint(req.PathValue("id"), 10, 32)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		template, err := handler.LinkRepository.GetTemplate(uint(id), userID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, ErrTemplateNotFound, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rows, err := decodeTemplateRows(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		items := make([]bulkItem, len(rows))
+		for i, row := range rows {
+			rendered, err := renderTemplate(&template.Link, row)
+			if err != nil {
+				items[i].Err = err
+				continue
+			}
+			items[i].Request = *rendered
+		}
+
+		handler.createBatch(w, req, userID, items)
+	}
+}
+
+// templateVariables lists the placeholder names used anywhere in the
+// request, in order of first use.
+func templateVariables(link *LinkCreateRequest) []string {
+	data, _ := json.Marshal(link)
+
+	variables := []string{}
+	seen := map[string]struct{}{}
+	for _, match := range placeholder.FindAllSubmatch(data, -1) {
+		name := string(match[1])
+		if _, ok := seen[name]; !ok {
+			seen[name] = struct{}{}
+			variables = append(variables, name)
+		}
+	}
+	return variables
+}
+
+// renderTemplate fills the placeholders in every string of the request
+// with values. Working on the JSON form reaches nested rules, variants
+// and tags without listing them one by one.
+func renderTemplate(link *LinkCreateRequest, values map[string]string) (*LinkCreateRequest, error) {
+	data, err := json.Marshal(link)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing string
+	data = placeholder.ReplaceAllFunc(data, func(match []byte) []byte {
+		name := string(placeholder.FindSubmatch(match)[1])
+		value, ok := values[name]
+		if !ok {
+			if missing == "" {
+				missing = name
+			}
+			return match
+		}
+		quoted, _ := json.Marshal(value)
+		return quoted[1 : len(quoted)-1]
+	})
+	if missing != "" {
+		return nil, fmt.Errorf(ErrMissingVariable, missing)
+	}
+
+	var rendered LinkCreateRequest
+	if err := json.Unmarshal(data, &rendered); err != nil {
+		return nil, err
+	}
+	return &rendered, nil
+}
+
+func decodeTemplateRows(req *http.Request) ([]map[string]string, error) {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case "text/csv":
+		return parseTemplateCsv(req.Body)
+	case "multipart/form-data":
+		file, _, err := req.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return parseTemplateCsv(file)
+	default:
+		body, err := request.Decode[LinkTemplateGenerateRequest](req.Body)
+		if err != nil {
+			return nil, err
+		}
+		if err := request.IsValid(body); err != nil {
+			return nil, err
+		}
+		return body.Rows, nil
+	}
+}
+
+func parseTemplateCsv(r io.Reader) ([]map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New(ErrTemplateCsv)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			row[name] = strings.TrimSpace(record[i])
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,74 @@
+package link_test
+
+import (
+	"demo/go-server/internal/link"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCreateTemplateValidatesWithSamples(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	wr := httptest.NewRecorder()
+	req := authedRequestWithBody(http.MethodPost, "/template", strings.NewReader(
+		`{"name":"cities","link":{"url":"https://{{city}}.example.com/sale","alias":"{{city}}-sale","utm_campaign":"{{ campaign }}"}}`))
+
+	handler.CreateTemplate()(wr, req)
+	if wr.Code != http.StatusCreated {
+		t.Fatalf("Got %d expected %d: %s", wr.Code, http.StatusCreated, wr.Body.String())
+	}
+	var template link.LinkTemplate
+	json.NewDecoder(wr.Body).Decode(&template)
+	if strings.Join(template.Variables, ",") != "city,campaign" {
+		t.Errorf("Got variables %v expected [city campaign]", template.Variables)
+	}
+
+	wr = httptest.NewRecorder()
+	req = authedRequestWithBody(http.MethodPost, "/template", strings.NewReader(
+		`{"name":"broken","link":{"url":"not a url {{city}}"}}`))
+
+	handler.CreateTemplate()(wr, req)
+	if wr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Got %d expected %d", wr.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestGenerateMissingVariable(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "link"}).
+		AddRow(3, 1, "cities", `{"url":"https://example.com/{{city}}"}`))
+
+	wr := httptest.NewRecorder()
+	req := authedRequestWithBody(http.MethodPost, "/template/3/generate", strings.NewReader(
+		"town\nparis\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	req.SetPathValue("id", "3")
+
+	handler.Generate()(wr, req)
+	if wr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Got %d expected %d", wr.Code, http.StatusUnprocessableEntity)
+	}
+	var data link.LinkBulkCreateResponse
+	json.NewDecoder(wr.Body).Decode(&data)
+	if data.Failed != 1 || data.Results[0].Error != "missing value for {{city}}" {
+		t.Errorf("Got %+v", data)
+	}
+}
@@ -0,0 +1,128 @@
+package link
+
+import (
+	"demo/go-server/pkg/response"
+	"log"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Trash lists the caller's deleted links, which keep their hash until
+// they are purged.
+func (handler *LinkHandler) Trash() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, ok := handler.currentUserID(w, req)
+		if !ok {
+			return
+		}
+
+		filter, err := handler.listFilter(req, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.Deleted = true
+
+		handler.writeLinkPage(w, req, filter)
+	}
+}
+
+func (handler *LinkHandler) Restore() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, ok := handler.currentUserID(w, req)
+		if !ok {
+			return
+		}
+
+		link, ok := handler.ownedDeletedLink(w, req, userID)
+		if !ok {
+			return
+		}
+
+		if err := handler.LinkRepository.Restore(link); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		link.DeletedAt = gorm.DeletedAt{}
+
+		handler.present(req, link)
+		response.WriteResponse(w, link, 200)
+	}
+}
+
+// Purge removes a link from the trash for good. Live links have to be
+// deleted first.
+func (handler *LinkHandler) Purge() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		userID, ok := handler.currentUserID(w, req)
+		if !ok {
+			return
+		}
+
+		link, ok := handler.ownedDeletedLink(w, req, userID)
+		if !ok {
+			return
+		}
+
+		if err := handler.LinkRepository.Purge(link.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response.WriteResponse(w, nil, 200)
+	}
+}
+
+// ownedDeletedLink is ownedLink for links in the trash.
+func (handler *LinkHandler) ownedDeletedLink(w http.ResponseWriter, req *http.Request, userID uint) (*Link, bool) {
+	return handler.ownedLinkFrom(w, req, userID, handler.LinkRepository.GetDeleted)
+}
+
+type TrashServiceDeps struct {
+	LinkRepository *LinkRepository
+	Retention      time.Duration
+	Interval       time.Duration
+}
+
+// TrashService purges links that have been in the trash for longer than
+// the retention period.
+type TrashService struct {
+	LinkRepository *LinkRepository
+	Retention      time.Duration
+	Interval       time.Duration
+}
+
+func NewTrashService(deps *TrashServiceDeps) *TrashService {
+	return &TrashService{
+		LinkRepository: deps.LinkRepository,
+		Retention:      deps.Retention,
+		Interval:       deps.Interval,
+	}
+}
+
+// PurgeExpired runs PurgeOnce every Interval. A zero retention keeps
+// the trash forever.
+func (s *TrashService) PurgeExpired() {
+	if s.Retention <= 0 || s.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		s.PurgeOnce(time.Now())
+		<-ticker.C
+	}
+}
+
+func (s *TrashService) PurgeOnce(now time.Time) {
+	purged, err := s.LinkRepository.PurgeDeletedBefore(now.Add(-s.Retention))
+	if err != nil {
+		log.Println("Trash purge failed:", err)
+		return
+	}
+	if purged > 0 {
+		log.Println("Purged", purged, "links from the trash")
+	}
+}
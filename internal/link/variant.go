@@ -0,0 +1,71 @@
+package link
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const variantCookieMaxAge = 30 * 24 * time.Hour
+
+func variantCookieName(link *Link) string {
+	return "ab_" + strconv.FormatUint(uint64(link.ID), 10)
+}
+
+// PickVariant chooses a variant with probability proportional to its
+// weight, or returns nil when the link has no variants.
+func (link *Link) PickVariant() *Variant {
+	total := 0
+	for _, variant := range link.Variants {
+		total += variant.Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	n := rand.IntN(total)
+	for i := range link.Variants {
+		n -= link.Variants[i].Weight
+		if n < 0 {
+			return &link.Variants[i]
+		}
+	}
+	return nil
+}
+
+func (link *Link) variantByID(id string) *Variant {
+	for i := range link.Variants {
+		if strconv.FormatUint(uint64(link.Variants[i].ID), 10) == id {
+			return &link.Variants[i]
+		}
+	}
+	return nil
+}
+
+// stickyVariant keeps a visitor on the variant they were served before,
+// remembered by ID in a cookie scoped to the short link, and assigns one
+// otherwise. Names are free text and not safe as a cookie value.
+func stickyVariant(w http.ResponseWriter, req *http.Request, link *Link) *Variant {
+	name := variantCookieName(link)
+	if cookie, err := req.Cookie(name); err == nil {
+		if variant := link.variantByID(cookie.Value); variant != nil {
+			return variant
+		}
+	}
+
+	variant := link.PickVariant()
+	if variant == nil {
+		return nil
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    strconv.FormatUint(uint64(variant.ID), 10),
+		Path:     "/" + link.Hash,
+		MaxAge:   int(variantCookieMaxAge.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return variant
+}
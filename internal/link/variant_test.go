@@ -0,0 +1,28 @@
+package link_test
+
+import (
+	"demo/go-server/internal/link"
+	"testing"
+)
+
+func TestPickVariantByWeight(t *testing.T) {
+	l := &link.Link{
+		Variants: []link.Variant{
+			{Name: "a", Weight: 1},
+			{Name: "b", Weight: 3},
+		},
+	}
+
+	counts := map[string]int{}
+	for range 10_000 {
+		counts[l.PickVariant().Name]++
+	}
+
+	// b should get about 75% of the traffic.
+	if counts["b"] < 7000 || counts["b"] > 8000 {
+		t.Errorf("Got %d visits for b out of 10000", counts["b"])
+	}
+	if (&link.Link{}).PickVariant() != nil {
+		t.Error("Expected no variant for a link without variants")
+	}
+}
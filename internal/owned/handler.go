@@ -0,0 +1,124 @@
+package owned
+
+import (
+	"demo/go-server/internal/user"
+	"demo/go-server/pkg/di"
+	"demo/go-server/pkg/request"
+	"demo/go-server/pkg/response"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// Handler serves the caller's records of one kind. New builds the record
+// called name for the user, with id set when it is an update; ErrNotFound
+// and ErrExists are the messages of the kind.
+type Handler[T any] struct {
+	Repository     *Repository[T]
+	UserRepository di.IUserRepository
+	New            func(id, userID uint, name string) *T
+	ErrNotFound    string
+	ErrExists      string
+}
+
+func (handler *Handler[T]) GetAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		owner, err := user.FromRequest(req, handler.UserRepository)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		response.WriteResponse(w, handler.Repository.GetAll(owner.ID), 200)
+	}
+}
+
+func (handler *Handler[T]) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		owner, err := user.FromRequest(req, handler.UserRepository)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		body, err := request.HandleBody[Request](&w, req)
+		if err != nil {
+			return
+		}
+
+		record, err := handler.Repository.Create(handler.New(0, owner.ID, body.Name))
+		if err != nil {
+			handler.writeError(w, err)
+			return
+		}
+
+		response.WriteResponse(w, record, 201)
+	}
+}
+
+func (handler *Handler[T]) Update() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		owner, err := user.FromRequest(req, handler.UserRepository)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		body, err := request.HandleBody[Request](&w, req)
+		if err != nil {
+			return
+		}
+
+		id, err := strconv.ParseUint(req.PathValue("id"), 10, 32)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		record, err := handler.Repository.Update(handler.New(uint(id), owner.ID, body.Name), owner.ID)
+		if err != nil {
+			handler.writeError(w, err)
+			return
+		}
+
+		response.WriteResponse(w, record, 200)
+	}
+}
+
+func (handler *Handler[T]) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		owner, err := user.FromRequest(req, handler.UserRepository)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		id, err := strconv.ParseUint(req.PathValue("id"), 10, 32)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := handler.Repository.Delete(uint(id), owner.ID); err != nil {
+			handler.writeError(w, err)
+			return
+		}
+
+		response.WriteResponse(w, nil, 200)
+	}
+}
+
+// writeError answers 404 for records the caller doesn't own, 409 for a
+// name already in use and 500 for anything else.
+func (handler *Handler[T]) writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, handler.ErrNotFound, http.StatusNotFound)
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		http.Error(w, handler.ErrExists, http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
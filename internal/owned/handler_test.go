@@ -0,0 +1,185 @@
+package owned_test
+
+import (
+	"demo/go-server/internal/owned"
+	"demo/go-server/internal/owned/ownedtest"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/gorm"
+)
+
+const (
+	errLabelNotFound = "label not found"
+	errLabelExists   = "label already exists"
+)
+
+type Label struct {
+	gorm.Model
+	Name   string `json:"name"`
+	UserID uint   `json:"user_id"`
+}
+
+func bootstrap() (*owned.Handler[Label], sqlmock.Sqlmock, error) {
+	database, mock, err := ownedtest.Database()
+	if err != nil {
+		return nil, nil, err
+	}
+	handler := owned.Handler[Label]{
+		Repository:     owned.NewRepository[Label](database),
+		UserRepository: &ownedtest.UserRepository{},
+		New: func(id, userID uint, name string) *Label {
+			return &Label{Model: gorm.Model{ID: id}, Name: name, UserID: userID}
+		},
+		ErrNotFound: errLabelNotFound,
+		ErrExists:   errLabelExists,
+	}
+	return &handler, mock, nil
+}
+
+func TestGetAllOfOwner(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	mock.ExpectQuery(`"labels" WHERE user_id = \$1`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "user_id"}).
+			AddRow(1, "promo", 1).
+			AddRow(2, "summer", 1))
+
+	wr := httptest.NewRecorder()
+	handler.GetAll()(wr, ownedtest.AuthedRequest(http.MethodGet, "/label", ""))
+
+	var labels []Label
+	json.NewDecoder(wr.Body).Decode(&labels)
+	if wr.Code != http.StatusOK || len(labels) != 2 {
+		t.Errorf("Got %d with %d labels", wr.Code, len(labels))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		code int
+		body string
+	}{
+		{"created", nil, http.StatusCreated, ""},
+		{"duplicate", ownedtest.ErrUniqueViolation, http.StatusConflict, errLabelExists},
+		{"database down", errors.New("connection refused"), http.StatusInternalServerError, "connection refused"},
+	}
+
+	for _, c := range cases {
+		handler, mock, err := bootstrap()
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+
+		mock.ExpectBegin()
+		insert := mock.ExpectQuery("INSERT").WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "promo", 1)
+		if c.err != nil {
+			insert.WillReturnError(c.err)
+			mock.ExpectRollback()
+		} else {
+			insert.WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+			mock.ExpectCommit()
+		}
+
+		wr := httptest.NewRecorder()
+		handler.Create()(wr, ownedtest.AuthedRequest(http.MethodPost, "/label", `{"name":"promo"}`))
+
+		if wr.Code != c.code {
+			t.Errorf("%s: got %d expected %d: %s", c.name, wr.Code, c.code, wr.Body.String())
+		}
+		if c.body != "" && strings.TrimSpace(wr.Body.String()) != c.body {
+			t.Errorf("%s: got %q expected %q", c.name, wr.Body.String(), c.body)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: %s", c.name, err)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	cases := []struct {
+		name string
+		rows *sqlmock.Rows
+		err  error
+		code int
+	}{
+		{"renamed", sqlmock.NewRows([]string{"id"}).AddRow(9), nil, http.StatusOK},
+		// The label belongs to another user, so the owner scoped update
+		// touches nothing.
+		{"foreign", sqlmock.NewRows([]string{"id"}), nil, http.StatusNotFound},
+		{"duplicate", nil, ownedtest.ErrUniqueViolation, http.StatusConflict},
+		{"database down", nil, errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, c := range cases {
+		handler, mock, err := bootstrap()
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+
+		mock.ExpectBegin()
+		update := mock.ExpectQuery(`UPDATE "labels" SET .* WHERE user_id = \$\d+ AND .*"id" = \$\d+`)
+		if c.err != nil {
+			update.WillReturnError(c.err)
+			mock.ExpectRollback()
+		} else {
+			update.WillReturnRows(c.rows)
+			mock.ExpectCommit()
+		}
+
+		wr := httptest.NewRecorder()
+		req := ownedtest.AuthedRequest(http.MethodPatch, "/label/9", `{"name":"promo"}`)
+		req.SetPathValue("id", "9")
+		handler.Update()(wr, req)
+
+		if wr.Code != c.code {
+			t.Errorf("%s: got %d expected %d: %s", c.name, wr.Code, c.code, wr.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: %s", c.name, err)
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	for affected, code := range map[int64]int{1: http.StatusOK, 0: http.StatusNotFound} {
+		handler, mock, err := bootstrap()
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM "labels" WHERE user_id = \$1 AND "labels"."id" = \$2`).WithArgs(1, 9).
+			WillReturnResult(sqlmock.NewResult(0, affected))
+		mock.ExpectCommit()
+
+		wr := httptest.NewRecorder()
+		req := ownedtest.AuthedRequest(http.MethodDelete, "/label/9", "")
+		req.SetPathValue("id", "9")
+		handler.Delete()(wr, req)
+
+		if wr.Code != code {
+			t.Errorf("Got %d expected %d: %s", wr.Code, code, wr.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
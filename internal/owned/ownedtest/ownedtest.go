@@ -0,0 +1,57 @@
+// Package ownedtest holds the fixtures shared by the tests of owner
+// scoped records.
+package ownedtest
+
+import (
+	"context"
+	"demo/go-server/internal/user"
+	"demo/go-server/pkg/db"
+	"demo/go-server/pkg/middleware"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Email is the caller of AuthedRequest, which UserRepository resolves to
+// user 1.
+const Email = "a@a.com"
+
+// ErrUniqueViolation is what Postgres returns for a duplicate key.
+var ErrUniqueViolation = &pgconn.PgError{Code: "23505", Message: "duplicate key value violates unique constraint"}
+
+type UserRepository struct {
+}
+
+func (repo *UserRepository) Create(u *user.User) (*user.User, error) {
+	return u, nil
+}
+
+func (repo *UserRepository) GetByEmail(email string) (*user.User, error) {
+	return &user.User{Model: gorm.Model{ID: 1}, Email: email}, nil
+}
+
+// Database opens a mocked database configured like db.NewDb.
+func Database() (*db.Db, sqlmock.Sqlmock, error) {
+	database, mock, err := sqlmock.New()
+	if err != nil {
+		return nil, nil, err
+	}
+	gormDb, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: database,
+	}), &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &db.Db{DB: gormDb}, mock, nil
+}
+
+func AuthedRequest(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	ctx := context.WithValue(req.Context(), middleware.ContextEmailKey, Email)
+	return req.WithContext(ctx)
+}
@@ -0,0 +1,5 @@
+package owned
+
+type Request struct {
+	Name string `json:"name" validate:"required,max=64"`
+}
@@ -0,0 +1,87 @@
+package owned
+
+import (
+	"demo/go-server/pkg/db"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Repository stores records that belong to one user and carry a name that
+// is unique per user, like tags and folders. Every read and write is
+// scoped to the owner.
+type Repository[T any] struct {
+	DataBase *db.Db
+}
+
+func NewRepository[T any](database *db.Db) *Repository[T] {
+	return &Repository[T]{
+		DataBase: database,
+	}
+}
+
+func (repo *Repository[T]) Create(record *T) (*T, error) {
+	result := repo.DataBase.DB.Create(record)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return record, nil
+}
+
+func (repo *Repository[T]) GetById(id, userID uint) (*T, error) {
+	var record T
+	result := repo.DataBase.DB.First(&record, "id = ? and user_id = ?", id, userID)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &record, nil
+}
+
+func (repo *Repository[T]) GetAll(userID uint) []T {
+	var records []T
+	repo.DataBase.DB.
+		Where("user_id = ?", userID).
+		Order("name asc").
+		Find(&records)
+
+	return records
+}
+
+// Update saves record, whose ID must be set, when it belongs to userID.
+func (repo *Repository[T]) Update(record *T, userID uint) (*T, error) {
+	result := repo.DataBase.DB.
+		Clauses(clause.Returning{}).
+		Where("user_id = ?", userID).
+		Updates(record)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return record, nil
+}
+
+// Delete removes the record for good, so the name can be reused.
+func (repo *Repository[T]) Delete(id, userID uint) error {
+	var record T
+	result := repo.DataBase.DB.
+		Unscoped().
+		Where("user_id = ?", userID).
+		Delete(&record, id)
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,11 @@
+package policy
+
+const (
+	ErrInvalidUrl       = "url is not valid"
+	ErrSchemeNotAllowed = "url scheme is not allowed"
+	ErrDomainDenied     = "destination domain is blocked"
+	ErrDomainNotAllowed = "destination domain is not on the allow list"
+	ErrPrivateNetwork   = "destination points to a private network"
+	ErrRedirectLoop     = "destination points back to the short link service"
+	ErrUnresolvable     = "destination host does not resolve"
+)
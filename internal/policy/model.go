@@ -0,0 +1,11 @@
+package policy
+
+import "gorm.io/gorm"
+
+// DomainRule allows or denies a domain and all of its subdomains as a
+// link destination, next to the lists from the config.
+type DomainRule struct {
+	gorm.Model
+	Domain string `json:"domain" gorm:"uniqueIndex"`
+	Allow  bool   `json:"allow"`
+}
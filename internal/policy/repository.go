@@ -0,0 +1,20 @@
+package policy
+
+import "demo/go-server/pkg/db"
+
+type DomainRuleRepository struct {
+	DataBase *db.Db
+}
+
+func NewDomainRuleRepository(database *db.Db) *DomainRuleRepository {
+	return &DomainRuleRepository{
+		DataBase: database,
+	}
+}
+
+func (repo *DomainRuleRepository) GetAll() []DomainRule {
+	var rules []DomainRule
+	repo.DataBase.DB.Order("domain asc").Find(&rules)
+
+	return rules
+}
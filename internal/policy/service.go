@@ -0,0 +1,256 @@
+package policy
+
+import (
+	"context"
+	"demo/go-server/configs"
+	"errors"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+type RuleSource interface {
+	GetAll() []DomainRule
+}
+
+// Policy decides whether a URL may be used as a link destination.
+type Policy struct {
+	AllowedSchemes []string
+	AllowDomains   []string
+	DenyDomains    []string
+	ShortDomains   []string
+	BlockPrivate   bool
+	Rules          RuleSource
+	Resolver       *net.Resolver
+}
+
+func NewPolicy(conf configs.PolicyConfig, rules RuleSource) *Policy {
+	return &Policy{
+		AllowedSchemes: conf.AllowedSchemes,
+		AllowDomains:   conf.AllowDomains,
+		DenyDomains:    conf.DenyDomains,
+		ShortDomains:   conf.ShortDomains,
+		BlockPrivate:   conf.BlockPrivate,
+		Rules:          rules,
+		Resolver:       net.DefaultResolver,
+	}
+}
+
+// Check validates rawUrl against the scheme allow list, the domain allow
+// and deny lists, the private network block and the service's own hosts.
+// ownHosts adds hosts that serve short links besides ShortDomains, e.g.
+// the Host of the current request.
+func (p *Policy) Check(ctx context.Context, rawUrl string, ownHosts ...string) error {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return errors.New(ErrInvalidUrl)
+	}
+
+	if !slices.Contains(p.AllowedSchemes, strings.ToLower(parsed.Scheme)) {
+		return errors.New(ErrSchemeNotAllowed)
+	}
+	if parsed.Hostname() == "" {
+		return errors.New(ErrInvalidUrl)
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+
+	for _, own := range append(ownHosts, p.ShortDomains...) {
+		if own = hostOnly(own); own != "" && host == own {
+			return errors.New(ErrRedirectLoop)
+		}
+	}
+
+	allow, deny := slices.Clone(p.AllowDomains), slices.Clone(p.DenyDomains)
+	for _, rule := range p.rules(ctx) {
+		if rule.Allow {
+			allow = append(allow, rule.Domain)
+		} else {
+			deny = append(deny, rule.Domain)
+		}
+	}
+	if matchDomain(host, deny) {
+		return errors.New(ErrDomainDenied)
+	}
+	if len(allow) > 0 && !matchDomain(host, allow) {
+		return errors.New(ErrDomainNotAllowed)
+	}
+
+	if p.BlockPrivate {
+		return p.checkPrivate(ctx, host)
+	}
+
+	return nil
+}
+
+type rulesKey struct{}
+
+// WithRules loads the domain rules once and keeps them in the returned
+// context, so a batch of Check calls made with it doesn't query them for
+// every url.
+func (p *Policy) WithRules(ctx context.Context) context.Context {
+	if p.Rules == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, rulesKey{}, p.Rules.GetAll())
+}
+
+func (p *Policy) rules(ctx context.Context) []DomainRule {
+	if rules, ok := ctx.Value(rulesKey{}).([]DomainRule); ok {
+		return rules
+	}
+	if p.Rules == nil {
+		return nil
+	}
+	return p.Rules.GetAll()
+}
+
+func (p *Policy) checkPrivate(ctx context.Context, host string) error {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.New(ErrPrivateNetwork)
+	}
+
+	if ip := parseHostIP(host); ip != nil {
+		if IsPrivateIP(ip) {
+			return errors.New(ErrPrivateNetwork)
+		}
+		return nil
+	}
+
+	// The host is resolved again when the destination is fetched, so a
+	// lookup failure here can't be told apart from a host that is only
+	// private for this server: refuse it.
+	addrs, err := p.Resolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return errors.New(ErrUnresolvable)
+	}
+	for _, addr := range addrs {
+		if IsPrivateIP(addr.IP) {
+			return errors.New(ErrPrivateNetwork)
+		}
+	}
+
+	return nil
+}
+
+// parseHostIP reads host as an IP address, accepting the short and
+// octal/hex IPv4 forms inet_aton takes (2130706433, 127.1, 0x7f000001,
+// 0177.0.0.1), since browsers and HTTP clients connect to those too.
+func parseHostIP(host string) net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip
+	}
+
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return nil
+	}
+	values := make([]uint64, len(parts))
+	for i, part := range parts {
+		value, ok := parseInetPart(part)
+		if !ok {
+			return nil
+		}
+		values[i] = value
+	}
+
+	// Every part but the last is one byte; the last fills the rest.
+	last := len(values) - 1
+	if values[last] >= 1<<(8*(4-last)) {
+		return nil
+	}
+	addr := values[last]
+	for i, value := range values[:last] {
+		if value > 0xff {
+			return nil
+		}
+		addr |= value << (8 * (3 - i))
+	}
+	return net.IPv4(byte(addr>>24), byte(addr>>16), byte(addr>>8), byte(addr))
+}
+
+// parseInetPart reads one part of an inet_aton address: hex after 0x,
+// octal after a leading 0, decimal otherwise.
+func parseInetPart(part string) (uint64, bool) {
+	base := 10
+	switch {
+	case len(part) > 2 && (part[:2] == "0x" || part[:2] == "0X"):
+		part, base = part[2:], 16
+	case len(part) > 1 && part[0] == '0':
+		part, base = part[1:], 8
+	}
+	value, err := strconv.ParseUint(part, base, 32)
+	return value, err == nil
+}
+
+var (
+	// thisNetwork is 0.0.0.0/8, which most stacks route to the host itself.
+	thisNetwork = &net.IPNet{IP: net.IPv4(0, 0, 0, 0), Mask: net.CIDRMask(8, 32)}
+	// sharedAddressSpace is the carrier-grade NAT range 100.64.0.0/10.
+	sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+	// nat64Prefix is the well-known NAT64 prefix 64:ff9b::/96, which
+	// carries an IPv4 address in its last four bytes.
+	nat64Prefix = &net.IPNet{IP: net.ParseIP("64:ff9b::"), Mask: net.CIDRMask(96, 128)}
+	// sixToFourPrefix is 2002::/16, which carries an IPv4 address in the
+	// two groups after the prefix.
+	sixToFourPrefix = &net.IPNet{IP: net.ParseIP("2002::"), Mask: net.CIDRMask(16, 128)}
+)
+
+// IsPrivateIP reports whether ip is not publicly routable. IPv4-mapped,
+// NAT64 and 6to4 IPv6 addresses are checked as the IPv4 address they
+// carry.
+func IsPrivateIP(ip net.IP) bool {
+	if ip16 := ip.To16(); ip16 != nil {
+		switch {
+		case nat64Prefix.Contains(ip16):
+			ip = ip16[12:16]
+		case sixToFourPrefix.Contains(ip16):
+			ip = ip16[2:6]
+		}
+	}
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	return thisNetwork.Contains(ip) ||
+		sharedAddressSpace.Contains(ip) ||
+		ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast()
+}
+
+// DialControl can be used as net.Dialer.Control to refuse connections to
+// private addresses after DNS resolution, which also covers redirects.
+func DialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip != nil && IsPrivateIP(ip) {
+		return errors.New(ErrPrivateNetwork)
+	}
+	return nil
+}
+
+func matchDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+func hostOnly(hostport string) string {
+	hostport = strings.ToLower(strings.TrimSpace(hostport))
+	if host, _, err := net.SplitHostPort(hostport); err == nil {
+		return host
+	}
+	return hostport
+}
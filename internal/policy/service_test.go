@@ -0,0 +1,125 @@
+package policy_test
+
+import (
+	"context"
+	"demo/go-server/configs"
+	"demo/go-server/internal/policy"
+	"net"
+	"testing"
+)
+
+type MockRuleSource struct {
+	calls int
+}
+
+func (source *MockRuleSource) GetAll() []policy.DomainRule {
+	source.calls++
+	return []policy.DomainRule{
+		{Domain: "evil.org", Allow: false},
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	p := policy.NewPolicy(configs.PolicyConfig{
+		AllowedSchemes: []string{"http", "https"},
+		DenyDomains:    []string{"blocked.com"},
+		ShortDomains:   []string{"sho.rt"},
+		BlockPrivate:   true,
+	}, &MockRuleSource{})
+
+	cases := map[string]string{
+		"https://93.184.216.34/page":     "",
+		"javascript:alert(1)":            policy.ErrSchemeNotAllowed,
+		"https:///path":                  policy.ErrInvalidUrl,
+		"ftp://93.184.216.34/file":       policy.ErrSchemeNotAllowed,
+		"http://localhost:8080":          policy.ErrPrivateNetwork,
+		"http://127.0.0.1/admin":         policy.ErrPrivateNetwork,
+		"http://10.0.0.8/":               policy.ErrPrivateNetwork,
+		"http://[::1]/":                  policy.ErrPrivateNetwork,
+		"http://169.254.169.254/latest":  policy.ErrPrivateNetwork,
+		"http://2130706433/":             policy.ErrPrivateNetwork,
+		"http://127.1/":                  policy.ErrPrivateNetwork,
+		"http://0x7f000001/":             policy.ErrPrivateNetwork,
+		"http://0177.0.0.1/":             policy.ErrPrivateNetwork,
+		"http://10.1/":                   policy.ErrPrivateNetwork,
+		"http://1572395042/":             "",
+		"http://[64:ff9b::7f00:1]/":      policy.ErrPrivateNetwork,
+		"http://[2002:a00:1::]/":         policy.ErrPrivateNetwork,
+		"http://nowhere.invalid/":        policy.ErrUnresolvable,
+		"https://sho.rt/abc":             policy.ErrRedirectLoop,
+		"https://api.example.test:8081/": policy.ErrRedirectLoop,
+		"https://cdn.blocked.com/x":      policy.ErrDomainDenied,
+		"https://www.evil.org":           policy.ErrDomainDenied,
+	}
+
+	for url, expected := range cases {
+		err := p.Check(context.Background(), url, "api.example.test:8081")
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != expected {
+			t.Errorf("%s: got %q expected %q", url, got, expected)
+		}
+	}
+}
+
+func TestPolicyAllowList(t *testing.T) {
+	p := policy.NewPolicy(configs.PolicyConfig{
+		AllowedSchemes: []string{"https"},
+		AllowDomains:   []string{"ourbrand.com"},
+	}, nil)
+
+	if err := p.Check(context.Background(), "https://shop.ourbrand.com/sale"); err != nil {
+		t.Errorf("Allowed domain rejected: %s", err)
+	}
+	if err := p.Check(context.Background(), "https://notourbrand.com/"); err == nil {
+		t.Error("Domain outside the allow list accepted")
+	}
+}
+
+func TestIsPrivateIP(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34":          false,
+		"2606:2800:220:1::1":     false,
+		"100.63.255.255":         false,
+		"100.64.0.1":             true,
+		"100.127.255.254":        true,
+		"100.128.0.1":            false,
+		"0.0.0.0":                true,
+		"0.1.2.3":                true,
+		"::ffff:127.0.0.1":       true,
+		"::ffff:10.1.2.3":        true,
+		"::ffff:100.64.0.1":      true,
+		"::ffff:169.254.169.254": true,
+		"::ffff:93.184.216.34":   false,
+		"fd00::1":                true,
+		"64:ff9b::7f00:1":        true,
+		"64:ff9b::5db8:d822":     false,
+		"2002:c0a8:101::1":       true,
+		"2002:5db8:d822::1":      false,
+	}
+
+	for raw, expected := range cases {
+		if got := policy.IsPrivateIP(net.ParseIP(raw)); got != expected {
+			t.Errorf("%s: got %v expected %v", raw, got, expected)
+		}
+	}
+}
+
+func TestPolicyWithRulesLoadsOnce(t *testing.T) {
+	source := &MockRuleSource{}
+	p := policy.NewPolicy(configs.PolicyConfig{
+		AllowedSchemes: []string{"https"},
+	}, source)
+
+	ctx := p.WithRules(context.Background())
+	for range 3 {
+		if err := p.Check(ctx, "https://www.evil.org"); err == nil || err.Error() != policy.ErrDomainDenied {
+			t.Errorf("Got %v expected %s", err, policy.ErrDomainDenied)
+		}
+	}
+	if source.calls != 1 {
+		t.Errorf("Rules loaded %d times expected 1", source.calls)
+	}
+}
@@ -0,0 +1,60 @@
+package stat
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/url"
+	"strings"
+)
+
+const (
+	IPModeTruncate = "truncate"
+	IPModeHash     = "hash"
+	IPModeNone     = "none"
+)
+
+// maxReferrerLength caps the referrer kept on a click event.
+const maxReferrerLength = 1024
+
+// AnonymizeIP reduces ip to what a click event keeps: its /24 (IPv4) or
+// /48 (IPv6) network, a keyed hash that still tells visitors apart, or
+// nothing. Unknown modes truncate.
+func AnonymizeIP(ip net.IP, mode, salt string) string {
+	if ip == nil {
+		return ""
+	}
+
+	switch mode {
+	case IPModeNone:
+		return ""
+	case IPModeHash:
+		mac := hmac.New(sha256.New, []byte(salt))
+		mac.Write(ip.To16())
+		return hex.EncodeToString(mac.Sum(nil)[:16])
+	default:
+		if v4 := ip.To4(); v4 != nil {
+			return v4.Mask(net.CIDRMask(24, 32)).String()
+		}
+		return ip.Mask(net.CIDRMask(48, 128)).String()
+	}
+}
+
+// cleanReferrer drops the query, fragment and credentials of a referrer,
+// which often carry tokens, and returns it with its lowercased host.
+func cleanReferrer(referrer string) (string, string) {
+	parsed, err := url.Parse(referrer)
+	if err != nil || parsed.Host == "" {
+		return "", ""
+	}
+
+	parsed.User = nil
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+	cleaned := parsed.String()
+	if len(cleaned) > maxReferrerLength {
+		cleaned = cleaned[:maxReferrerLength]
+	}
+	return cleaned, strings.ToLower(parsed.Hostname())
+}
@@ -0,0 +1,39 @@
+package stat_test
+
+import (
+	"demo/go-server/internal/stat"
+	"net"
+	"testing"
+)
+
+func TestAnonymizeIP(t *testing.T) {
+	cases := []struct {
+		ip       string
+		mode     string
+		expected string
+	}{
+		{"203.0.113.77", stat.IPModeTruncate, "203.0.113.0"},
+		{"2001:db8:85a3:8d3:1319:8a2e:370:7348", stat.IPModeTruncate, "2001:db8:85a3::"},
+		{"203.0.113.77", stat.IPModeNone, ""},
+		{"203.0.113.77", "", "203.0.113.0"},
+	}
+	for _, c := range cases {
+		if got := stat.AnonymizeIP(net.ParseIP(c.ip), c.mode, ""); got != c.expected {
+			t.Errorf("%s %q: got %q expected %q", c.ip, c.mode, got, c.expected)
+		}
+	}
+}
+
+func TestAnonymizeIPHash(t *testing.T) {
+	ip := net.ParseIP("203.0.113.77")
+	first := stat.AnonymizeIP(ip, stat.IPModeHash, "salt")
+	if len(first) != 32 || first == ip.String() {
+		t.Fatalf("Got %q", first)
+	}
+	if again := stat.AnonymizeIP(ip, stat.IPModeHash, "salt"); again != first {
+		t.Errorf("Got %q expected %q", again, first)
+	}
+	if other := stat.AnonymizeIP(ip, stat.IPModeHash, "pepper"); other == first {
+		t.Error("Hash should depend on the salt")
+	}
+}
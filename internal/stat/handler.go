@@ -2,24 +2,38 @@ package stat
 
 import (
 	"demo/go-server/configs"
+	"demo/go-server/internal/user"
+	"demo/go-server/pkg/di"
 	"demo/go-server/pkg/middleware"
 	"demo/go-server/pkg/response"
 	"net/http"
+	"strconv"
 	"time"
 )
 
 const (
-	GroupByDay   = "day"
-	GroupByMonth = "month"
+	GroupByDay      = "day"
+	GroupByMonth    = "month"
+	SplitByVariant  = "variant"
+	SplitByCampaign = "campaign"
+
+	BreakdownByCountry  = "country"
+	BreakdownByReferrer = "referrer"
+	BreakdownByBrowser  = "browser"
+	BreakdownByOS       = "os"
+	BreakdownByDevice   = "device"
+	BreakdownByLanguage = "language"
 )
 
 type StatHandlerDeps struct {
 	StatRepository *StatRepository
+	UserRepository di.IUserRepository
 	Config         *configs.Config
 }
 
 type StatHandler struct {
 	StatRepository *StatRepository
+	UserRepository di.IUserRepository
 }
 
 type LinkResponse struct {
@@ -29,12 +43,20 @@ type LinkResponse struct {
 func NewStatHandler(router *http.ServeMux, deps StatHandlerDeps) {
 	handler := &StatHandler{
 		StatRepository: deps.StatRepository,
+		UserRepository: deps.UserRepository,
 	}
 	router.Handle("GET /stat", middleware.IsAuthed(handler.GetStat(), deps.Config))
+	router.Handle("GET /stat/breakdown", middleware.IsAuthed(handler.GetBreakdown(), deps.Config))
 }
 
 func (handler *StatHandler) GetStat() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
+		owner, err := user.FromRequest(req, handler.UserRepository)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
 		from, err := parseDateParam("from", req)
 		if err != nil {
 			http.Error(w, "Invalid from", http.StatusBadRequest)
@@ -53,11 +75,86 @@ func (handler *StatHandler) GetStat() http.HandlerFunc {
 			return
 		}
 
-		stats := handler.StatRepository.GetAll(by, from, to)
+		split := req.URL.Query().Get("split")
+		if split != SplitByVariant && split != SplitByCampaign && split != "" {
+			http.Error(w, "Invalid split", http.StatusBadRequest)
+			return
+		}
+
+		var linkID uint64
+		if linkStr := req.URL.Query().Get("link"); linkStr != "" {
+			linkID, err = strconv.ParseUint(linkStr, 10, 32)
+			if err != nil {
+				http.Error(w, "Invalid link", http.StatusBadRequest)
+				return
+			}
+		}
+
+		stats := handler.StatRepository.GetAll(StatFilter{
+			UserID:   owner.ID,
+			LinkID:   uint(linkID),
+			Campaign: req.URL.Query().Get("campaign"),
+			By:       by,
+			Split:    split,
+			From:     from,
+			To:       to,
+		})
 		response.WriteResponse(w, stats, 200)
 	}
 }
 
+// GetBreakdown answers where clicks came from: click events counted by
+// country, referrer host, browser, os, device or language. to includes
+// the whole day.
+func (handler *StatHandler) GetBreakdown() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		owner, err := user.FromRequest(req, handler.UserRepository)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		by := req.URL.Query().Get("by")
+		if _, ok := breakdownColumns[by]; !ok {
+			http.Error(w, "Invalid by", http.StatusBadRequest)
+			return
+		}
+
+		from, err := parseDateParam("from", req)
+		if err != nil {
+			http.Error(w, "Invalid from", http.StatusBadRequest)
+			return
+		}
+
+		to, err := parseDateParam("to", req)
+		if err != nil {
+			http.Error(w, "Invalid to", http.StatusBadRequest)
+			return
+		}
+		if !to.IsZero() {
+			to = to.AddDate(0, 0, 1)
+		}
+
+		var linkID uint64
+		if linkStr := req.URL.Query().Get("link"); linkStr != "" {
+			linkID, err = strconv.ParseUint(linkStr, 10, 32)
+			if err != nil {
+				http.Error(w, "Invalid link", http.StatusBadRequest)
+				return
+			}
+		}
+
+		breakdown := handler.StatRepository.GetBreakdown(BreakdownFilter{
+			UserID: owner.ID,
+			LinkID: uint(linkID),
+			By:     by,
+			From:   from,
+			To:     to,
+		})
+		response.WriteResponse(w, breakdown, 200)
+	}
+}
+
 func parseDateParam(dateParam string, req *http.Request) (time.Time, error) {
 	var date time.Time
 	paramStr := req.URL.Query().Get(dateParam)
@@ -1,13 +1,34 @@
 package stat
 
 import (
+	"time"
+
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
 type Stat struct {
 	gorm.Model
-	LinkId uint           `json:"link_id"`
-	Clicks int            `json:"clicks"`
-	Date   datatypes.Date `json:"date"`
+	LinkId  uint           `json:"link_id" gorm:"index"`
+	Clicks  int            `json:"clicks"`
+	Refused int            `json:"refused"`
+	Variant string         `json:"variant,omitempty" gorm:"not null;default:''"`
+	Date    datatypes.Date `json:"date"`
+}
+
+// ClickEvent is one visit of a link, kept raw next to the daily Stat
+// counters so clicks can be broken down by where they came from.
+type ClickEvent struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	ClickedAt    time.Time `json:"clicked_at" gorm:"index:idx_click_events_link_time,priority:2"`
+	LinkID       uint      `json:"link_id" gorm:"index:idx_click_events_link_time,priority:1"`
+	Variant      string    `json:"variant,omitempty"`
+	Referrer     string    `json:"referrer,omitempty"`
+	ReferrerHost string    `json:"referrer_host,omitempty"`
+	Browser      string    `json:"browser"`
+	OS           string    `json:"os"`
+	Device       string    `json:"device"`
+	IP           string    `json:"ip,omitempty"`
+	Country      string    `json:"country,omitempty"`
+	Language     string    `json:"language,omitempty"`
 }
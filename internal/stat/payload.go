@@ -1,6 +1,16 @@
 package stat
 
 type GetStatResponse struct {
-	Period string `json:"period"`
-	Sum    int    `json:"sum"`
+	Period   string `json:"period"`
+	Variant  string `json:"variant,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Sum      int    `json:"sum"`
+	Refused  int    `json:"refused"`
+}
+
+// BreakdownResponse is the number of clicks with one value of the
+// breakdown column. An empty value means unknown.
+type BreakdownResponse struct {
+	Value  string `json:"value"`
+	Clicks int64  `json:"clicks"`
 }
@@ -11,47 +11,156 @@ type StatRepository struct {
 	DataBase *db.Db
 }
 
+// StatFilter selects the stats GetAll aggregates: the caller's links in
+// the date range, optionally one link or campaign, grouped by By and
+// split by Split.
+type StatFilter struct {
+	UserID   uint
+	LinkID   uint
+	Campaign string
+	By       string
+	Split    string
+	From     time.Time
+	To       time.Time
+}
+
+// BreakdownFilter selects the click events GetBreakdown counts: the
+// caller's links, optionally one link, clicked in [From, To). Zero times
+// leave that end open.
+type BreakdownFilter struct {
+	UserID uint
+	LinkID uint
+	By     string
+	From   time.Time
+	To     time.Time
+}
+
+// breakdownColumns are the click event columns a breakdown can group by.
+var breakdownColumns = map[string]string{
+	BreakdownByCountry:  "click_events.country",
+	BreakdownByReferrer: "click_events.referrer_host",
+	BreakdownByBrowser:  "click_events.browser",
+	BreakdownByOS:       "click_events.os",
+	BreakdownByDevice:   "click_events.device",
+	BreakdownByLanguage: "click_events.language",
+}
+
+// breakdownLimit is how many of the most frequent values a breakdown
+// returns.
+const breakdownLimit = 100
+
 func NewStatRepository(database *db.Db) *StatRepository {
 	return &StatRepository{
 		DataBase: database,
 	}
 }
 
-func (repo *StatRepository) AddClick(linkId uint) {
+// AddClick counts a visit, per A/B variant when the link has variants.
+func (repo *StatRepository) AddClick(linkId uint, variant string) {
+	repo.increment(linkId, variant, func(stat *Stat) {
+		stat.Clicks += 1
+	})
+}
+
+// AddRefusal counts a visit that was turned away, e.g. because the link
+// expired, without touching the click counter.
+func (repo *StatRepository) AddRefusal(linkId uint) {
+	repo.increment(linkId, "", func(stat *Stat) {
+		stat.Refused += 1
+	})
+}
+
+func (repo *StatRepository) increment(linkId uint, variant string, apply func(stat *Stat)) {
 	var stat Stat
 	currentDate := datatypes.Date(time.Now())
-	repo.DataBase.DB.Find(&stat, "link_id = ? and date = ?", linkId, currentDate)
+	repo.DataBase.DB.Find(&stat, "link_id = ? and date = ? and variant = ?", linkId, currentDate, variant)
 
 	if stat.ID == 0 {
 		newStat := Stat{
-			LinkId: linkId,
-			Clicks: 1,
-			Date:   currentDate,
+			LinkId:  linkId,
+			Date:    currentDate,
+			Variant: variant,
 		}
+		apply(&newStat)
 		repo.DataBase.DB.Create(&newStat)
 	} else {
-		stat.Clicks += 1
+		apply(&stat)
 		repo.DataBase.DB.Save(&stat)
 	}
 }
 
-func (repo *StatRepository) GetAll(by string, from, to time.Time) []GetStatResponse {
+func (repo *StatRepository) GetAll(filter StatFilter) []GetStatResponse {
 	var stats []GetStatResponse
 	var selectQuery string
 
-	switch by {
+	switch filter.By {
 	case GroupByMonth:
-		selectQuery = "to_char(date, 'YYYY-MM') as period, sum(clicks)"
+		selectQuery = "to_char(stats.date, 'YYYY-MM') as period, sum(stats.clicks), sum(stats.refused) as refused"
 	default:
-		selectQuery = "to_char(date, 'YYYY-MM-DD') as period, sum(clicks)"
+		selectQuery = "to_char(stats.date, 'YYYY-MM-DD') as period, sum(stats.clicks), sum(stats.refused) as refused"
 	}
 
-	repo.DataBase.DB.Table("stats").
+	query := repo.DataBase.DB.Table("stats").
+		Joins("join links on links.id = stats.link_id").
+		Where("stats.date BETWEEN ? AND ?", filter.From, filter.To).
+		Where("links.user_id = ?", filter.UserID)
+
+	if filter.LinkID != 0 {
+		query = query.Where("stats.link_id = ?", filter.LinkID)
+	}
+	if filter.Campaign != "" {
+		query = query.Where("links.utm_campaign = ?", filter.Campaign)
+	}
+
+	group := "period"
+	switch filter.Split {
+	case SplitByVariant:
+		selectQuery += ", stats.variant"
+		group = "period, stats.variant"
+	case SplitByCampaign:
+		selectQuery += ", links.utm_campaign as campaign"
+		group = "period, links.utm_campaign"
+	}
+
+	query.
 		Select(selectQuery).
-		Where("date BETWEEN ? AND ?", from, to).
-		Group("period").
-		Order("period").
+		Group(group).
+		Order(group).
 		Scan(&stats)
 
 	return stats
 }
+
+// AddClickEvent stores one raw visit.
+func (repo *StatRepository) AddClickEvent(click *ClickEvent) error {
+	return repo.DataBase.DB.Create(click).Error
+}
+
+// GetBreakdown counts click events per value of the filter's By column,
+// most frequent first.
+func (repo *StatRepository) GetBreakdown(filter BreakdownFilter) []BreakdownResponse {
+	column := breakdownColumns[filter.By]
+	query := repo.DataBase.DB.Table("click_events").
+		Joins("join links on links.id = click_events.link_id").
+		Where("links.user_id = ?", filter.UserID)
+
+	if filter.LinkID != 0 {
+		query = query.Where("click_events.link_id = ?", filter.LinkID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("click_events.clicked_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("click_events.clicked_at < ?", filter.To)
+	}
+
+	breakdown := []BreakdownResponse{}
+	query.
+		Select(column + " as value, count(*) as clicks").
+		Group(column).
+		Order("clicks desc, value").
+		Limit(breakdownLimit).
+		Scan(&breakdown)
+
+	return breakdown
+}
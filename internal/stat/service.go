@@ -2,36 +2,88 @@ package stat
 
 import (
 	"demo/go-server/pkg/event"
+	"demo/go-server/pkg/geoip"
+	"demo/go-server/pkg/useragent"
 	"log"
+	"time"
 )
 
 type StatServiceDeps struct {
 	EventBus       *event.EventBus
 	StatRepository *StatRepository
+	GeoIP          geoip.Reader
+	IPMode         string
+	IPSalt         string
 }
 
 type StatService struct {
 	EventBus       *event.EventBus
 	StatRepository *StatRepository
+	GeoIP          geoip.Reader
+	IPMode         string
+	IPSalt         string
 }
 
 func NewStatService(deps *StatServiceDeps) *StatService {
 	return &StatService{
 		EventBus:       deps.EventBus,
 		StatRepository: deps.StatRepository,
+		GeoIP:          deps.GeoIP,
+		IPMode:         deps.IPMode,
+		IPSalt:         deps.IPSalt,
 	}
 }
 
+// AddClick counts visits and refusals in the daily stats and logs every
+// visit as a click event.
 func (s *StatService) AddClick() {
 	for msg := range s.EventBus.Subscribe() {
 		switch msg.Type {
 		case event.LinkVisited:
-			id, ok := msg.Data.(uint)
+			visit, ok := msg.Data.(event.Visit)
 			if !ok {
 				log.Fatalln("Bad EventLinkVisited Data: ", msg.Data)
 				continue
 			}
-			s.StatRepository.AddClick(id)
+			s.StatRepository.AddClick(visit.LinkID, visit.Variant)
+			if err := s.StatRepository.AddClickEvent(s.clickEvent(visit)); err != nil {
+				log.Println("Click event for link", visit.LinkID, "not saved:", err)
+			}
+		case event.LinkRefused:
+			visit, ok := msg.Data.(event.Visit)
+			if !ok {
+				log.Fatalln("Bad EventLinkRefused Data: ", msg.Data)
+				continue
+			}
+			s.StatRepository.AddRefusal(visit.LinkID)
 		}
 	}
 }
+
+// clickEvent keeps what the visit tells about the visitor, with the IP
+// anonymized and looked up in the local GeoIP database first.
+func (s *StatService) clickEvent(visit event.Visit) *ClickEvent {
+	agent := useragent.Parse(visit.UserAgent)
+	referrer, referrerHost := cleanReferrer(visit.Referrer)
+
+	click := &ClickEvent{
+		ClickedAt:    visit.Time,
+		LinkID:       visit.LinkID,
+		Variant:      visit.Variant,
+		Referrer:     referrer,
+		ReferrerHost: referrerHost,
+		Browser:      agent.Browser,
+		OS:           agent.OS,
+		Device:       agent.Device,
+		IP:           AnonymizeIP(visit.IP, s.IPMode, s.IPSalt),
+		Language:     visit.Language,
+	}
+	if click.ClickedAt.IsZero() {
+		click.ClickedAt = time.Now()
+	}
+	if s.GeoIP != nil && visit.IP != nil {
+		click.Country = s.GeoIP.Country(visit.IP)
+	}
+
+	return click
+}
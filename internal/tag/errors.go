@@ -0,0 +1,6 @@
+package tag
+
+const (
+	ErrTagNotFound = "tag not found"
+	ErrTagExists   = "tag already exists"
+)
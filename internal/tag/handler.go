@@ -0,0 +1,39 @@
+package tag
+
+import (
+	"demo/go-server/configs"
+	"demo/go-server/internal/owned"
+	"demo/go-server/pkg/di"
+	"demo/go-server/pkg/middleware"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+type TagHandlerDeps struct {
+	TagRepository  *TagRepository
+	UserRepository di.IUserRepository
+	Config         *configs.Config
+}
+
+type TagHandler = owned.Handler[Tag]
+
+func NewTagHandler(router *http.ServeMux, deps TagHandlerDeps) {
+	handler := NewHandler(deps.TagRepository, deps.UserRepository)
+	router.Handle("GET /tag", middleware.IsAuthed(handler.GetAll(), deps.Config))
+	router.Handle("POST /tag", middleware.IsAuthed(handler.Create(), deps.Config))
+	router.Handle("PATCH /tag/{id}", middleware.IsAuthed(handler.Update(), deps.Config))
+	router.Handle("DELETE /tag/{id}", middleware.IsAuthed(handler.Delete(), deps.Config))
+}
+
+func NewHandler(repo *TagRepository, users di.IUserRepository) *TagHandler {
+	return &TagHandler{
+		Repository:     repo.Repository,
+		UserRepository: users,
+		New: func(id, userID uint, name string) *Tag {
+			return &Tag{Model: gorm.Model{ID: id}, Name: name, UserID: userID}
+		},
+		ErrNotFound: ErrTagNotFound,
+		ErrExists:   ErrTagExists,
+	}
+}
@@ -0,0 +1,74 @@
+package tag_test
+
+import (
+	"demo/go-server/internal/owned/ownedtest"
+	"demo/go-server/internal/tag"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func bootstrap() (*tag.TagHandler, sqlmock.Sqlmock, error) {
+	database, mock, err := ownedtest.Database()
+	if err != nil {
+		return nil, nil, err
+	}
+	return tag.NewHandler(tag.NewTagRepository(database), &ownedtest.UserRepository{}), mock, nil
+}
+
+func TestCreateDuplicateTag(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "tags"`).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "promo", 1).
+		WillReturnError(ownedtest.ErrUniqueViolation)
+	mock.ExpectRollback()
+
+	wr := httptest.NewRecorder()
+	handler.Create()(wr, ownedtest.AuthedRequest(http.MethodPost, "/tag", `{"name":"promo"}`))
+
+	if wr.Code != http.StatusConflict {
+		t.Errorf("Got %d expected %d", wr.Code, http.StatusConflict)
+	}
+	if strings.TrimSpace(wr.Body.String()) != tag.ErrTagExists {
+		t.Errorf("Got %q expected %q", wr.Body.String(), tag.ErrTagExists)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeleteForeignTag(t *testing.T) {
+	handler, mock, err := bootstrap()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "tags" WHERE user_id = \$1 AND "tags"."id" = \$2`).WithArgs(1, 9).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	wr := httptest.NewRecorder()
+	req := ownedtest.AuthedRequest(http.MethodDelete, "/tag/9", "")
+	req.SetPathValue("id", "9")
+	handler.Delete()(wr, req)
+
+	if wr.Code != http.StatusNotFound {
+		t.Errorf("Got %d expected %d", wr.Code, http.StatusNotFound)
+	}
+	if strings.TrimSpace(wr.Body.String()) != tag.ErrTagNotFound {
+		t.Errorf("Got %q expected %q", wr.Body.String(), tag.ErrTagNotFound)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,9 @@
+package tag
+
+import "gorm.io/gorm"
+
+type Tag struct {
+	gorm.Model
+	Name   string `json:"name" gorm:"uniqueIndex:idx_tag_user_name"`
+	UserID uint   `json:"user_id" gorm:"uniqueIndex:idx_tag_user_name"`
+}
@@ -0,0 +1,61 @@
+package tag
+
+import (
+	"demo/go-server/internal/owned"
+	"demo/go-server/pkg/db"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TagRepository stores the caller's tags. Deleting a tag takes its
+// link_tags rows with it.
+type TagRepository struct {
+	*owned.Repository[Tag]
+}
+
+func NewTagRepository(database *db.Db) *TagRepository {
+	return &TagRepository{
+		Repository: owned.NewRepository[Tag](database),
+	}
+}
+
+// GetOrCreate returns the user's tags with the given names, creating the
+// ones that do not exist yet.
+func (repo *TagRepository) GetOrCreate(userID uint, names []string) ([]Tag, error) {
+	if len(names) == 0 {
+		return []Tag{}, nil
+	}
+
+	var tags []Tag
+	err := repo.DataBase.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		tags, err = FindOrCreate(tx, userID, names)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// FindOrCreate is GetOrCreate on tx, for callers that store the tags
+// together with other rows.
+func FindOrCreate(tx *gorm.DB, userID uint, names []string) ([]Tag, error) {
+	tags := make([]Tag, len(names))
+	for i, name := range names {
+		tags[i] = Tag{Name: name, UserID: userID}
+	}
+
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("user_id = ? and name in ?", userID, names).Find(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
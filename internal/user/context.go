@@ -0,0 +1,27 @@
+package user
+
+import (
+	"demo/go-server/pkg/middleware"
+	"errors"
+	"net/http"
+)
+
+type emailLookup interface {
+	GetByEmail(email string) (*User, error)
+}
+
+// FromRequest returns the user behind the email that middleware.IsAuthed
+// stores in the request context.
+func FromRequest(req *http.Request, repo emailLookup) (*User, error) {
+	email, ok := req.Context().Value(middleware.ContextEmailKey).(string)
+	if !ok || email == "" {
+		return nil, errors.New(ErrUserNotFound)
+	}
+
+	existedUser, err := repo.GetByEmail(email)
+	if err != nil || existedUser == nil {
+		return nil, errors.New(ErrUserNotFound)
+	}
+
+	return existedUser, nil
+}
@@ -0,0 +1,5 @@
+package user
+
+const (
+	ErrUserNotFound = "user not found"
+)
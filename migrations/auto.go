@@ -1,8 +1,12 @@
 package main
 
 import (
+	"demo/go-server/internal/domain"
+	"demo/go-server/internal/folder"
 	"demo/go-server/internal/link"
+	"demo/go-server/internal/policy"
 	"demo/go-server/internal/stat"
+	"demo/go-server/internal/tag"
 	"demo/go-server/internal/user"
 	"os"
 
@@ -22,5 +26,21 @@ func main() {
 		panic(err)
 	}
 
-	db.AutoMigrate(&link.Link{}, &user.User{}, &stat.Stat{})
+	db.AutoMigrate(&user.User{}, &tag.Tag{}, &folder.Folder{}, &domain.Domain{}, &link.Link{}, &link.RoutingRule{}, &link.Variant{}, &link.LinkRevision{}, &link.LinkTemplate{}, &link.LinkHealth{}, &stat.Stat{}, &stat.ClickEvent{}, &policy.DomainRule{})
+
+	// Hashes used to be unique across all hosts; they are now unique per
+	// domain.
+	if db.Migrator().HasIndex(&link.Link{}, "idx_links_hash") {
+		if err := db.Migrator().DropIndex(&link.Link{}, "idx_links_hash"); err != nil {
+			panic(err)
+		}
+	}
+
+	// Hosts used to be unique as soon as they were claimed; only verified
+	// hosts are unique now.
+	if db.Migrator().HasIndex(&domain.Domain{}, "idx_domains_host") {
+		if err := db.Migrator().DropIndex(&domain.Domain{}, "idx_domains_host"); err != nil {
+			panic(err)
+		}
+	}
 }
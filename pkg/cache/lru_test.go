@@ -0,0 +1,52 @@
+package cache_test
+
+import (
+	"demo/go-server/pkg/cache"
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	var evicted []string
+	lru := cache.NewLRU(2, func(key string, _ int) {
+		evicted = append(evicted, key)
+	})
+
+	lru.Set("a", 1, time.Minute)
+	lru.Set("b", 2, time.Minute)
+	lru.Get("a")
+	lru.Set("c", 3, time.Minute)
+
+	if _, ok := lru.Get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	if value, ok := lru.Get("a"); !ok || value != 1 {
+		t.Errorf("Got %d, %t expected 1, true", value, ok)
+	}
+	if len(evicted) != 1 || evicted[0] != "b" {
+		t.Errorf("Got evicted %v expected [b]", evicted)
+	}
+
+	stats := lru.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Evictions != 1 || stats.Entries != 2 {
+		t.Errorf("Got %+v", stats)
+	}
+}
+
+func TestLRUExpires(t *testing.T) {
+	lru := cache.NewLRU[string, int](10, nil)
+
+	lru.Set("a", 1, time.Millisecond)
+	lru.Set("b", 2, time.Minute)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := lru.Get("a"); ok {
+		t.Error("a should have expired")
+	}
+	if _, ok := lru.Get("b"); !ok {
+		t.Error("b should still be cached")
+	}
+	if entries := lru.Stats().Entries; entries != 1 {
+		t.Errorf("Got %d entries expected 1", entries)
+	}
+}
@@ -12,7 +12,9 @@ type Db struct {
 }
 
 func NewDb(conf *configs.Config) *Db {
-	db, err := gorm.Open(postgres.Open(conf.Db.Dsn), &gorm.Config{})
+	// TranslateError turns driver errors such as unique violations into
+	// gorm errors, so handlers can tell them apart from other failures.
+	db, err := gorm.Open(postgres.Open(conf.Db.Dsn), &gorm.Config{TranslateError: true})
 
 	if err != nil {
 		panic(err)
@@ -3,7 +3,8 @@ package di
 import "demo/go-server/internal/user"
 
 type IStatRepository interface {
-	AddClick(linkId uint)
+	AddClick(linkId uint, variant string)
+	AddRefusal(linkId uint)
 }
 
 type IUserRepository interface {
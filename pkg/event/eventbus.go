@@ -1,7 +1,15 @@
 package event
 
+import (
+	"net"
+	"sync"
+	"time"
+)
+
 const (
 	LinkVisited = "link.visited"
+	LinkRefused = "link.refused"
+	LinkCreated = "link.created"
 )
 
 type Event struct {
@@ -9,20 +17,44 @@ type Event struct {
 	Data any
 }
 
+// Visit is the payload of LinkVisited and LinkRefused events. For
+// visits the request context is filled in as well; what of it is stored,
+// and how, is up to the consumer.
+type Visit struct {
+	LinkID    uint
+	Variant   string
+	Time      time.Time
+	Referrer  string
+	UserAgent string
+	IP        net.IP
+	Language  string
+}
+
+// EventBus fans every published event out to all subscribers, so each
+// consumer sees every event and picks the types it cares about.
 type EventBus struct {
-	bus chan Event
+	mu          sync.RWMutex
+	subscribers []chan Event
 }
 
 func NewEventBus() *EventBus {
-	return &EventBus{
-		bus: make(chan Event),
-	}
+	return &EventBus{}
 }
 
 func (e *EventBus) Publish(event Event) {
-	e.bus <- event
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, subscriber := range e.subscribers {
+		subscriber <- event
+	}
 }
 
 func (e *EventBus) Subscribe() <-chan Event {
-	return e.bus
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	subscriber := make(chan Event, 64)
+	e.subscribers = append(e.subscribers, subscriber)
+	return subscriber
 }
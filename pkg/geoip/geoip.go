@@ -0,0 +1,125 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+const ErrBadRecord = "geoip: bad record"
+
+// Reader maps an IP address to an ISO 3166-1 alpha-2 country code. An
+// empty code means the country is unknown.
+type Reader interface {
+	Country(ip net.IP) string
+}
+
+// NewReader opens the local database at path, or returns a reader that
+// knows no countries when path is empty.
+func NewReader(path string) (Reader, error) {
+	if path == "" {
+		return NoopReader{}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return NewCsvReader(file)
+}
+
+type NoopReader struct{}
+
+func (NoopReader) Country(net.IP) string {
+	return ""
+}
+
+type ipRange struct {
+	from    net.IP
+	to      net.IP
+	country string
+}
+
+// CsvReader holds a database of "network,country" rows in memory, where
+// network is a CIDR block such as 81.2.69.0/24 or 2001:db8::/32, and
+// looks addresses up with a binary search. A header row is skipped.
+type CsvReader struct {
+	ranges []ipRange
+}
+
+func NewCsvReader(r io.Reader) (*CsvReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+
+	var ranges []ipRange
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 {
+			return nil, errors.New(ErrBadRecord)
+		}
+
+		_, network, err := net.ParseCIDR(strings.TrimSpace(record[0]))
+		if err != nil {
+			if len(ranges) == 0 {
+				continue
+			}
+			return nil, errors.New(ErrBadRecord)
+		}
+
+		from := network.IP.To16()
+		to := make(net.IP, len(from))
+		mask := network.Mask
+		if len(mask) == net.IPv4len {
+			mask = append(net.CIDRMask(96, 128)[:12:12], mask...)
+		}
+		for i := range from {
+			to[i] = from[i] | ^mask[i]
+		}
+
+		ranges = append(ranges, ipRange{
+			from:    from,
+			to:      to,
+			country: strings.ToUpper(strings.TrimSpace(record[1])),
+		})
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return bytes.Compare(ranges[i].from, ranges[j].from) < 0
+	})
+
+	return &CsvReader{ranges: ranges}, nil
+}
+
+func (r *CsvReader) Country(ip net.IP) string {
+	ip = ip.To16()
+	if ip == nil {
+		return ""
+	}
+
+	i := sort.Search(len(r.ranges), func(i int) bool {
+		return bytes.Compare(r.ranges[i].from, ip) > 0
+	})
+	if i == 0 {
+		return ""
+	}
+
+	candidate := r.ranges[i-1]
+	if bytes.Compare(ip, candidate.to) <= 0 {
+		return candidate.country
+	}
+	return ""
+}
@@ -0,0 +1,36 @@
+package geoip_test
+
+import (
+	"demo/go-server/pkg/geoip"
+	"net"
+	"strings"
+	"testing"
+)
+
+const database = `network,country
+81.2.69.0/24,gb
+2.16.0.0/13,de
+2001:db8::/32,nl
+`
+
+func TestCsvReader(t *testing.T) {
+	reader, err := geoip.NewCsvReader(strings.NewReader(database))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"81.2.69.142":     "GB",
+		"2.23.255.255":    "DE",
+		"2.24.0.0":        "",
+		"2001:db8::1":     "NL",
+		"10.0.0.1":        "",
+		"81.2.70.1":       "",
+		"::ffff:2.16.0.1": "DE",
+	}
+	for ip, expected := range cases {
+		if got := reader.Country(net.ParseIP(ip)); got != expected {
+			t.Errorf("%s: got %q expected %q", ip, got, expected)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package qr
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"strings"
+
+	_ "image/jpeg"
+
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+)
+
+var ErrBadColor = errors.New("colour must be a hex value like 000 or 1a2b3c")
+
+// Options control how a code is rendered. Logo, when set, is drawn over
+// the centre of the code, so it should be paired with a high level.
+type Options struct {
+	Size       int
+	Level      qrcode.RecoveryLevel
+	Foreground color.RGBA
+	Background color.RGBA
+	Logo       image.Image
+}
+
+// logoRatio is the share of the code width covered by the logo; level H
+// recovers up to 30% of the modules, the logo stays well below that.
+const logoRatio = 5
+
+// ParseLevel maps L, M, Q and H to a recovery level.
+func ParseLevel(level string) (qrcode.RecoveryLevel, bool) {
+	switch strings.ToUpper(level) {
+	case "L":
+		return qrcode.Low, true
+	case "M":
+		return qrcode.Medium, true
+	case "Q":
+		return qrcode.High, true
+	case "H":
+		return qrcode.Highest, true
+	}
+	return 0, false
+}
+
+// ParseColor reads "rgb" or "rrggbb" hex, with or without a leading '#'.
+func ParseColor(value string) (color.RGBA, error) {
+	value = strings.TrimPrefix(value, "#")
+	if len(value) == 3 {
+		value = string([]byte{value[0], value[0], value[1], value[1], value[2], value[2]})
+	}
+
+	var c color.RGBA
+	if len(value) != 6 {
+		return c, ErrBadColor
+	}
+	if _, err := fmt.Sscanf(value, "%02x%02x%02x", &c.R, &c.G, &c.B); err != nil {
+		return c, ErrBadColor
+	}
+	c.A = 0xff
+	return c, nil
+}
+
+// Logo is a decoded logo image with a digest of the file it came from,
+// so caches can tell when the file was replaced.
+type Logo struct {
+	Image  image.Image
+	Digest string
+}
+
+// LoadLogo decodes a PNG or JPEG file; an empty path means no logo.
+func LoadLogo(path string) (*Logo, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	return &Logo{Image: img, Digest: fmt.Sprintf("%x", sum)}, nil
+}
+
+func encode(content string, opts Options) (*qrcode.QRCode, error) {
+	code, err := qrcode.New(content, opts.Level)
+	if err != nil {
+		return nil, err
+	}
+	code.ForegroundColor = opts.Foreground
+	code.BackgroundColor = opts.Background
+	return code, nil
+}
+
+// PNG renders content as a Size x Size PNG image.
+func PNG(content string, opts Options) ([]byte, error) {
+	code, err := encode(content, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	src := code.Image(opts.Size)
+	img := image.NewRGBA(src.Bounds())
+	draw.Draw(img, img.Bounds(), src, image.Point{}, draw.Src)
+
+	if opts.Logo != nil {
+		side := img.Bounds().Dx() / logoRatio
+		offset := (img.Bounds().Dx() - side) / 2
+		target := image.Rect(offset, offset, offset+side, offset+side)
+		draw.Draw(img, target, &image.Uniform{opts.Background}, image.Point{}, draw.Src)
+		draw.Draw(img, target, scale(opts.Logo, side), image.Point{}, draw.Over)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG renders content as a single path scaled to Size pixels.
+func SVG(content string, opts Options) ([]byte, error) {
+	code, err := encode(content, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	bitmap := code.Bitmap()
+	modules := len(bitmap)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.Size, opts.Size, modules, modules)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="%s"/>`, modules, modules, hex(opts.Background))
+	fmt.Fprintf(&buf, `<path fill="%s" d="`, hex(opts.Foreground))
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	buf.WriteString(`"/>`)
+
+	if opts.Logo != nil {
+		side := float64(modules) / logoRatio
+		offset := (float64(modules) - side) / 2
+		logo, err := logoDataUri(opts.Logo)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, `<rect x="%g" y="%g" width="%g" height="%g" fill="%s"/>`, offset, offset, side, side, hex(opts.Background))
+		fmt.Fprintf(&buf, `<image x="%g" y="%g" width="%g" height="%g" href="%s"/>`, offset, offset, side, side, logo)
+	}
+	buf.WriteString("</svg>")
+	return buf.Bytes(), nil
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func logoDataUri(logo image.Image) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, logo); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// scale resizes src to a side x side square with nearest neighbour
+// sampling, which is plenty for a small centre mark.
+func scale(src image.Image, side int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, side, side))
+	bounds := src.Bounds()
+	for y := 0; y < side; y++ {
+		sy := bounds.Min.Y + y*bounds.Dy()/side
+		for x := 0; x < side; x++ {
+			sx := bounds.Min.X + x*bounds.Dx()/side
+			dst.Set(x, y, src.At(sx, sy))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,45 @@
+package request
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns the address of the caller. With trustProxy the first
+// address of X-Forwarded-For wins, which is only safe behind a proxy that
+// sets the header itself.
+func ClientIP(r *http.Request, trustProxy bool) net.IP {
+	if trustProxy {
+		forwarded := r.Header.Get("X-Forwarded-For")
+		if forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := net.ParseIP(strings.TrimSpace(first)); ip != nil {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// Languages returns the primary subtags of Accept-Language in the order
+// they are listed, lowercased and without those refused with q=0.
+func Languages(r *http.Request) []string {
+	var languages []string
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.ReplaceAll(strings.TrimSpace(params), " ", "") == "q=0" {
+			continue
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if primary != "" && primary != "*" {
+			languages = append(languages, primary)
+		}
+	}
+	return languages
+}
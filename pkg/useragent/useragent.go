@@ -0,0 +1,117 @@
+package useragent
+
+import "strings"
+
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformDesktop = "desktop"
+	PlatformOther   = "other"
+)
+
+// Platform classifies a User-Agent header into the platforms routing
+// rules can target. Bots and empty headers count as PlatformOther.
+func Platform(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	switch {
+	case ua == "":
+		return PlatformOther
+	case strings.Contains(ua, "iphone"),
+		strings.Contains(ua, "ipad"),
+		strings.Contains(ua, "ipod"):
+		return PlatformIOS
+	case strings.Contains(ua, "android"):
+		return PlatformAndroid
+	case strings.Contains(ua, "bot"),
+		strings.Contains(ua, "spider"),
+		strings.Contains(ua, "crawl"):
+		return PlatformOther
+	case strings.Contains(ua, "windows"),
+		strings.Contains(ua, "macintosh"),
+		strings.Contains(ua, "mac os x"),
+		strings.Contains(ua, "x11"),
+		strings.Contains(ua, "linux"),
+		strings.Contains(ua, "cros"):
+		return PlatformDesktop
+	default:
+		return PlatformOther
+	}
+}
+
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+	DeviceBot     = "bot"
+	DeviceOther   = "other"
+)
+
+// Agent is what a User-Agent header tells about the visitor's software.
+type Agent struct {
+	Browser string
+	OS      string
+	Device  string
+}
+
+// browsers is checked in order: most browsers also name the engines of
+// the ones before them, e.g. Edge claims to be Chrome and Safari.
+var browsers = []struct {
+	token string
+	name  string
+}{
+	{"edg/", "Edge"},
+	{"opr/", "Opera"},
+	{"samsungbrowser/", "Samsung Internet"},
+	{"crios/", "Chrome"},
+	{"chrome/", "Chrome"},
+	{"fxios/", "Firefox"},
+	{"firefox/", "Firefox"},
+	{"safari/", "Safari"},
+}
+
+// Parse classifies a User-Agent header by browser, operating system and
+// device class. Parts it does not recognise are "Other".
+func Parse(userAgent string) Agent {
+	ua := strings.ToLower(userAgent)
+	agent := Agent{Browser: "Other", OS: "Other", Device: DeviceOther}
+	if ua == "" {
+		return agent
+	}
+
+	for _, browser := range browsers {
+		if strings.Contains(ua, browser.token) {
+			agent.Browser = browser.name
+			break
+		}
+	}
+
+	switch {
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		agent.OS = "iOS"
+	case strings.Contains(ua, "android"):
+		agent.OS = "Android"
+	case strings.Contains(ua, "windows"):
+		agent.OS = "Windows"
+	case strings.Contains(ua, "macintosh"), strings.Contains(ua, "mac os x"):
+		agent.OS = "macOS"
+	case strings.Contains(ua, "cros"):
+		agent.OS = "ChromeOS"
+	case strings.Contains(ua, "linux"), strings.Contains(ua, "x11"):
+		agent.OS = "Linux"
+	}
+
+	switch {
+	case strings.Contains(ua, "bot"), strings.Contains(ua, "spider"), strings.Contains(ua, "crawl"):
+		agent.Device = DeviceBot
+	case strings.Contains(ua, "ipad"), strings.Contains(ua, "tablet"),
+		agent.OS == "Android" && !strings.Contains(ua, "mobile"):
+		agent.Device = DeviceTablet
+	case agent.OS == "iOS", agent.OS == "Android", strings.Contains(ua, "mobile"):
+		agent.Device = DeviceMobile
+	case agent.OS != "Other":
+		agent.Device = DeviceDesktop
+	}
+
+	return agent
+}
@@ -0,0 +1,45 @@
+package useragent_test
+
+import (
+	"demo/go-server/pkg/useragent"
+	"testing"
+)
+
+func TestPlatform(t *testing.T) {
+	cases := map[string]string{
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15":     useragent.PlatformIOS,
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/120.0 Mobile": useragent.PlatformAndroid,
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0":       useragent.PlatformDesktop,
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/605.1.15 Version/17.0":  useragent.PlatformDesktop,
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)":        useragent.PlatformOther,
+		"": useragent.PlatformOther,
+	}
+	for ua, expected := range cases {
+		if got := useragent.Platform(ua); got != expected {
+			t.Errorf("%q: got %s expected %s", ua, got, expected)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	cases := map[string]useragent.Agent{
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Version/17.0 Mobile/15E148 Safari/604.1": {
+			Browser: "Safari", OS: "iOS", Device: useragent.DeviceMobile,
+		},
+		"Mozilla/5.0 (Linux; Android 14; SM-X710) AppleWebKit/537.36 Chrome/120.0 Safari/537.36": {
+			Browser: "Chrome", OS: "Android", Device: useragent.DeviceTablet,
+		},
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36 Edg/120.0": {
+			Browser: "Edge", OS: "Windows", Device: useragent.DeviceDesktop,
+		},
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)": {
+			Browser: "Other", OS: "Other", Device: useragent.DeviceBot,
+		},
+		"": {Browser: "Other", OS: "Other", Device: useragent.DeviceOther},
+	}
+	for ua, expected := range cases {
+		if got := useragent.Parse(ua); got != expected {
+			t.Errorf("%q: got %+v expected %+v", ua, got, expected)
+		}
+	}
+}